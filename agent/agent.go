@@ -47,6 +47,8 @@ const (
 	StorageDir       = "STORAGE_DIR"
 	StorageAddr      = "STORAGE_ADDR"
 	AgentServiceName = "AGENT_SERVICE_NAME"
+	RsyslogLogDir    = "RSYSLOG_LOG_DIR"
+	RsyslogSpoolDir  = "RSYSLOG_SPOOL_DIR"
 )
 
 // The Config interface is the sole way that the agent gets access to the
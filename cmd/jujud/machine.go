@@ -79,6 +79,7 @@ var (
 	ensureMongoAdminUser     = mongo.EnsureAdminUser
 	newSingularRunner        = singular.New
 	peergrouperNew           = peergrouper.New
+	performUpgrade           = upgrades.PerformUpgrade
 
 	// reportOpenedAPI is exposed for tests to know when
 	// the State has been successfully opened.
@@ -473,10 +474,13 @@ func (a *MachineAgent) StateWorker() (worker.Worker, error) {
 				if len(cert) == 0 || len(key) == 0 {
 					return nil, &fatalError{"configuration does not have state server cert/key"}
 				}
-				dataDir := agentConfig.DataDir()
-				logDir := agentConfig.LogDir()
-				return apiserver.NewServer(
-					st, fmt.Sprintf(":%d", port), cert, key, dataDir, logDir)
+				return apiserver.NewServerWithConfig(st, apiserver.ServerConfig{
+					Addrs:   []string{fmt.Sprintf(":%d", port)},
+					Cert:    cert,
+					Key:     key,
+					DataDir: agentConfig.DataDir(),
+					LogDir:  agentConfig.LogDir(),
+				})
 			})
 			a.startWorkerAfterUpgrade(singularRunner, "cleaner", func() (worker.Worker, error) {
 				return cleaner.NewCleaner(st), nil
@@ -757,14 +761,15 @@ func (a *MachineAgent) runUpgrades(
 	}
 	var err error
 	writeErr := a.ChangeConfig(func(agentConfig agent.ConfigSetter) {
-		context := upgrades.NewContext(agentConfig, apiState, st)
+		progress := upgradeProgressReporter(apiState, a.Tag())
+		context := upgrades.NewContext(agentConfig, apiState, st, progress)
 		for _, job := range jobs {
 			target := upgradeTarget(job)
 			if target == "" {
 				continue
 			}
 			logger.Infof("starting upgrade from %v to %v for %v %q", from, version.Current, target, a.Tag())
-			if err = upgrades.PerformUpgrade(from.Number, target, context); err != nil {
+			if err = performUpgrade(from.Number, target, context); err != nil {
 				err = fmt.Errorf("cannot perform upgrade from %v to %v for %v %q: %v", from, version.Current, target, a.Tag(), err)
 				return
 			}
@@ -774,9 +779,43 @@ func (a *MachineAgent) runUpgrades(
 	if writeErr != nil {
 		return fmt.Errorf("cannot write updated agent configuration: %v", writeErr)
 	}
+	if err != nil {
+		// Replace any progress reported by the upgrade steps with
+		// the reason the upgrade failed.
+		if statusErr := setUpgradeStatus(apiState, a.Tag(), params.StatusError, err.Error()); statusErr != nil {
+			logger.Warningf("cannot set upgrade status: %v", statusErr)
+		}
+		return err
+	}
+	// Clear any progress reported by the upgrade steps.
+	if err := setUpgradeStatus(apiState, a.Tag(), params.StatusStarted, ""); err != nil {
+		logger.Warningf("cannot clear upgrade status: %v", err)
+	}
 	return nil
 }
 
+// setUpgradeStatus records info about an upgrade in the status of the
+// machine with the given tag, so that it shows in juju status.
+func setUpgradeStatus(apiState *api.State, tag string, status params.Status, info string) error {
+	m, err := apiState.Machiner().Machine(tag)
+	if err != nil {
+		return err
+	}
+	return m.SetStatus(status, info, nil)
+}
+
+// upgradeProgressReporter returns an upgrades.ProgressFunc that records
+// the progress of upgrade steps in the status of the machine with the
+// given tag.
+func upgradeProgressReporter(apiState *api.State, tag string) upgrades.ProgressFunc {
+	return func(done, total int, description string) {
+		info := fmt.Sprintf("upgrading to %v (%d/%d): %s", version.Current.Number, done, total, description)
+		if err := setUpgradeStatus(apiState, tag, params.StatusStarted, info); err != nil {
+			logger.Warningf("cannot report upgrade progress: %v", err)
+		}
+	}
+}
+
 func upgradeTarget(job params.MachineJob) upgrades.Target {
 	switch job {
 	case params.JobManageEnviron:
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,7 +16,9 @@ import (
 	"github.com/juju/juju/agent"
 	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/state"
+	"github.com/juju/juju/state/api/params"
 	coretesting "github.com/juju/juju/testing"
+	"github.com/juju/juju/upgrades"
 	"github.com/juju/juju/version"
 )
 
@@ -64,6 +67,45 @@ func (s *UpgradeSuite) TestUpgradeStepsHostMachine(c *gc.C) {
 	s.assertHostUpgrades(c)
 }
 
+func (s *UpgradeSuite) TestUpgradeProgressReporter(c *gc.C) {
+	apiState, m := s.OpenAPIAsNewMachine(c, state.JobHostUnits)
+	progress := upgradeProgressReporter(apiState, m.Tag())
+	progress(2, 5, "reconfiguring rsyslog")
+
+	status, info, _, err := m.Status()
+	c.Assert(err, gc.IsNil)
+	c.Assert(status, gc.Equals, params.StatusStarted)
+	expectInfo := fmt.Sprintf("upgrading to %v (2/5): reconfiguring rsyslog", version.Current.Number)
+	c.Assert(info, gc.Equals, expectInfo)
+
+	err = setUpgradeStatus(apiState, m.Tag(), params.StatusStarted, "")
+	c.Assert(err, gc.IsNil)
+	_, info, _, err = m.Status()
+	c.Assert(err, gc.IsNil)
+	c.Assert(info, gc.Equals, "")
+}
+
+func (s *UpgradeSuite) TestFailedUpgradeReportedInStatus(c *gc.C) {
+	s.agentSuite.PatchValue(&version.Current, s.upgradeToVersion)
+	s.agentSuite.PatchValue(&performUpgrade, func(version.Number, upgrades.Target, upgrades.Context) error {
+		return fmt.Errorf("boom")
+	})
+	oldVersion := s.upgradeToVersion
+	oldVersion.Minor--
+	m, _, _ := s.primeAgent(c, oldVersion, state.JobHostUnits)
+	a := s.newAgent(c, m)
+	apiState := s.OpenAPIAsMachine(c, m.Tag(), initialMachinePassword, state.BootstrapNonce)
+
+	jobs := []params.MachineJob{params.JobHostUnits}
+	err := a.runUpgrades(nil, apiState, jobs, a.CurrentConfig())
+	c.Assert(err, gc.ErrorMatches, "cannot perform upgrade from .*: boom")
+
+	status, info, _, err := m.Status()
+	c.Assert(err, gc.IsNil)
+	c.Assert(status, gc.Equals, params.StatusError)
+	c.Assert(info, gc.Matches, "cannot perform upgrade from .*: boom")
+}
+
 func (s *UpgradeSuite) assertUpgradeSteps(c *gc.C, job state.MachineJob) {
 	s.agentSuite.PatchValue(&version.Current, s.upgradeToVersion)
 	err := s.State.SetEnvironAgentVersion(s.upgradeToVersion.Number)
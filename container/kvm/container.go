@@ -18,6 +18,9 @@ type kvmContainer struct {
 	// this allows for checking when we don't know, but using a
 	// value if we already know it (like in the list situation).
 	started *bool
+	// state is the libvirt state of the container, valid whenever
+	// started is not nil.
+	state string
 }
 
 var _ Container = (*kvmContainer)(nil)
@@ -64,6 +67,7 @@ func (c *kvmContainer) Stop() error {
 	}
 	// Make started state unknown again.
 	c.started = nil
+	c.state = ""
 	logger.Debugf("Stop %s", c.name)
 	return DestroyMachine(c.name)
 }
@@ -72,14 +76,38 @@ func (c *kvmContainer) IsRunning() bool {
 	if c.started != nil {
 		return *c.started
 	}
-	machines, err := ListMachines()
-	if err != nil {
+	if err := c.Refresh(); err != nil {
 		return false
 	}
-	c.started = isRunning(machines[c.name])
 	return *c.started
 }
 
+func (c *kvmContainer) State() string {
+	if c.started == nil {
+		if err := c.Refresh(); err != nil {
+			return ""
+		}
+	}
+	return c.state
+}
+
+func (c *kvmContainer) Metrics() (InstanceMetrics, error) {
+	if !c.IsRunning() {
+		return InstanceMetrics{}, fmt.Errorf("container %q is not running", c.name)
+	}
+	return MachineMetrics(c.name)
+}
+
+func (c *kvmContainer) Refresh() error {
+	machines, err := ListMachines()
+	if err != nil {
+		return err
+	}
+	c.state = machines[c.name]
+	c.started = isRunning(c.state)
+	return nil
+}
+
 func (c *kvmContainer) String() string {
 	return fmt.Sprintf("<KVM container %v>", *c)
 }
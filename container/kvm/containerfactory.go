@@ -33,6 +33,7 @@ func (factory *containerFactory) List() (result []Container, err error) {
 			factory: factory,
 			name:    hostname,
 			started: isRunning(status),
+			state:   status,
 		})
 
 	}
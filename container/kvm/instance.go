@@ -21,16 +21,30 @@ func (kvm *kvmInstance) Id() instance.Id {
 	return instance.Id(kvm.id)
 }
 
-// Status implements instance.Instance.Status.
+// Status implements instance.Instance.Status. As well as "running"
+// and "stopped", it reports "paused" for suspended containers and
+// "crashed" for containers that have failed.
 func (kvm *kvmInstance) Status() string {
 	if kvm.container.IsRunning() {
 		return "running"
 	}
+	switch kvm.container.State() {
+	case "paused", "pmsuspended":
+		return "paused"
+	case "crashed":
+		return "crashed"
+	}
 	return "stopped"
 }
 
-func (*kvmInstance) Refresh() error {
-	return nil
+// Refresh implements instance.Instance.Refresh.
+func (kvm *kvmInstance) Refresh() error {
+	return kvm.container.Refresh()
+}
+
+// Metrics returns the current CPU and memory usage of the instance.
+func (kvm *kvmInstance) Metrics() (InstanceMetrics, error) {
+	return kvm.container.Metrics()
 }
 
 func (kvm *kvmInstance) Addresses() ([]instance.Address, error) {
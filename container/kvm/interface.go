@@ -4,6 +4,8 @@
 package kvm
 
 import (
+	"time"
+
 	"github.com/juju/juju/container"
 )
 
@@ -18,6 +20,14 @@ type StartParams struct {
 	RootDisk     uint64 // GB
 }
 
+// InstanceMetrics holds the resource usage of a running container.
+type InstanceMetrics struct {
+	// CPUTime is the total vCPU time used by the container.
+	CPUTime time.Duration
+	// RSS is the resident set size of the container's process, in KiB.
+	RSS uint64
+}
+
 // Container represents a virtualized container instance and provides
 // operations to create, maintain and destroy the container.
 type Container interface {
@@ -34,6 +44,19 @@ type Container interface {
 	// IsRunning returns wheter or not the container is running and active.
 	IsRunning() bool
 
+	// State returns the libvirt state of the container, one of:
+	// running, idle, paused, shutdown, shut off, crashed, dying,
+	// pmsuspended, or the empty string if it is not known.
+	State() string
+
+	// Metrics returns the current resource usage of the container.
+	// It returns an error if the container is not running.
+	Metrics() (InstanceMetrics, error)
+
+	// Refresh re-reads the state of the container from the host, so
+	// that IsRunning reflects any changes made outside of juju.
+	Refresh() error
+
 	// String returns information about the container, like the name, state,
 	// and process id.
 	String() string
@@ -4,15 +4,20 @@
 package kvm_test
 
 import (
+	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"time"
 
 	"github.com/juju/loggo"
+	jujutesting "github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "launchpad.net/gocheck"
 
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/container"
 	"github.com/juju/juju/container/kvm"
+	"github.com/juju/juju/container/kvm/mock"
 	kvmtesting "github.com/juju/juju/container/kvm/testing"
 	containertesting "github.com/juju/juju/container/testing"
 	"github.com/juju/juju/instance"
@@ -108,6 +113,109 @@ func (s *KVMSuite) TestDestroyContainer(c *gc.C) {
 	c.Assert(filepath.Join(s.RemovedDir, name), jc.IsDirectory)
 }
 
+func (s *KVMSuite) TestInstanceStatusAfterRefresh(c *gc.C) {
+	inst := containertesting.CreateContainer(c, s.manager, "1/kvm/0")
+	c.Assert(inst.Status(), gc.Equals, "running")
+
+	// Stop the container behind the instance's back; the
+	// instance does not see the change until it is refreshed.
+	mock.SetState(s.Factory.New(string(inst.Id())), "shut off")
+	c.Assert(inst.Status(), gc.Equals, "running")
+
+	err := inst.Refresh()
+	c.Assert(err, gc.IsNil)
+	c.Assert(inst.Status(), gc.Equals, "stopped")
+}
+
+func (s *KVMSuite) TestContainerRefresh(c *gc.C) {
+	listFile := filepath.Join(c.MkDir(), "virsh-list")
+	writeList := func(list string) {
+		err := ioutil.WriteFile(listFile, []byte(list), 0644)
+		c.Assert(err, gc.IsNil)
+	}
+	jujutesting.PatchExecutable(c, s, "virsh", fmt.Sprintf("#!/bin/sh\ncat %q\n", listFile))
+	writeList(" 3     juju-machine-1-kvm-0           running\n")
+
+	kvmContainer := kvm.NewContainer("juju-machine-1-kvm-0")
+	c.Assert(kvmContainer.IsRunning(), jc.IsTrue)
+	c.Assert(kvmContainer.State(), gc.Equals, "running")
+
+	// The state is cached until the container is refreshed.
+	writeList(" 3     juju-machine-1-kvm-0           shut off\n")
+	c.Assert(kvmContainer.IsRunning(), jc.IsTrue)
+	c.Assert(kvmContainer.Refresh(), gc.IsNil)
+	c.Assert(kvmContainer.IsRunning(), jc.IsFalse)
+	c.Assert(kvmContainer.State(), gc.Equals, "shut off")
+
+	// A container that is no longer listed has no state.
+	writeList("")
+	c.Assert(kvmContainer.Refresh(), gc.IsNil)
+	c.Assert(kvmContainer.IsRunning(), jc.IsFalse)
+	c.Assert(kvmContainer.State(), gc.Equals, "")
+}
+
+func (s *KVMSuite) TestInstanceStatus(c *gc.C) {
+	inst := containertesting.CreateContainer(c, s.manager, "1/kvm/0")
+	kvmContainer := s.Factory.New(string(inst.Id()))
+	for i, test := range []struct {
+		state  string
+		status string
+	}{
+		{"running", "running"},
+		{"paused", "paused"},
+		{"pmsuspended", "paused"},
+		{"crashed", "crashed"},
+		{"shut off", "stopped"},
+		{"dying", "stopped"},
+	} {
+		c.Logf("test %d: %s", i, test.state)
+		mock.SetState(kvmContainer, test.state)
+		c.Check(inst.Refresh(), gc.IsNil)
+		c.Check(inst.Status(), gc.Equals, test.status)
+	}
+}
+
+func (s *KVMSuite) TestInstanceMetrics(c *gc.C) {
+	inst := containertesting.CreateContainer(c, s.manager, "1/kvm/0")
+	metricsInst, ok := inst.(interface {
+		Metrics() (kvm.InstanceMetrics, error)
+	})
+	c.Assert(ok, jc.IsTrue)
+	_, err := metricsInst.Metrics()
+	c.Assert(err, gc.IsNil)
+
+	err = s.Factory.New(string(inst.Id())).Stop()
+	c.Assert(err, gc.IsNil)
+	_, err = metricsInst.Metrics()
+	c.Assert(err, gc.ErrorMatches, `container ".*" is not running`)
+}
+
+func (*KVMSuite) TestParseMachineMetrics(c *gc.C) {
+	info := `
+Id:             3
+Name:           juju-machine-1-kvm-0
+State:          running
+CPU(s):         1
+CPU time:       42.5s
+Max memory:     524288 KiB
+`
+	memstat := `
+actual 524288
+rss 204800
+`
+	metrics, err := kvm.ParseMachineMetrics(info, memstat)
+	c.Assert(err, gc.IsNil)
+	c.Assert(metrics, gc.Equals, kvm.InstanceMetrics{
+		CPUTime: 42500 * time.Millisecond,
+		RSS:     204800,
+	})
+
+	_, err = kvm.ParseMachineMetrics("State: shut off\n", memstat)
+	c.Assert(err, gc.ErrorMatches, "cannot find CPU time in dominfo output")
+	_, err = kvm.ParseMachineMetrics(info, "actual 524288\n")
+	c.Assert(err, gc.ErrorMatches, "cannot find rss in dommemstat output")
+}
+
 type ConstraintsSuite struct {
 	coretesting.BaseSuite
 }
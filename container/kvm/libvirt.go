@@ -17,7 +17,9 @@ package kvm
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/juju/utils"
 )
@@ -28,6 +30,11 @@ var (
 	// first part is the opaque identifier we don't care about
 	// then the hostname, and lastly the status.
 	machineListPattern = regexp.MustCompile(`(?m)^\s+\d+\s+(?P<hostname>[-\w]+)\s+(?P<status>.+)\s*$`)
+
+	// The regular expressions for extracting the CPU time from
+	// 'virsh dominfo' and the resident set size from 'virsh dommemstat'.
+	cpuTimePattern = regexp.MustCompile(`(?m)^CPU time:\s+([0-9.]+)s\s*$`)
+	rssPattern     = regexp.MustCompile(`(?m)^rss\s+(\d+)\s*$`)
 )
 
 // run the command and return the combined output.
@@ -130,3 +137,39 @@ func ListMachines() (map[string]string, error) {
 	}
 	return result, nil
 }
+
+// MachineMetrics returns the vCPU time and resident set size of the
+// running virtual machine identified by hostname.
+func MachineMetrics(hostname string) (InstanceMetrics, error) {
+	info, err := run("virsh", "dominfo", hostname)
+	if err != nil {
+		return InstanceMetrics{}, err
+	}
+	memstat, err := run("virsh", "dommemstat", hostname)
+	if err != nil {
+		return InstanceMetrics{}, err
+	}
+	return parseMachineMetrics(info, memstat)
+}
+
+func parseMachineMetrics(info, memstat string) (InstanceMetrics, error) {
+	var metrics InstanceMetrics
+	match := cpuTimePattern.FindStringSubmatch(info)
+	if match == nil {
+		return metrics, fmt.Errorf("cannot find CPU time in dominfo output")
+	}
+	seconds, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return metrics, fmt.Errorf("invalid CPU time %q: %v", match[1], err)
+	}
+	metrics.CPUTime = time.Duration(seconds * float64(time.Second))
+	match = rssPattern.FindStringSubmatch(memstat)
+	if match == nil {
+		return metrics, fmt.Errorf("cannot find rss in dommemstat output")
+	}
+	metrics.RSS, err = strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return metrics, fmt.Errorf("invalid rss %q: %v", match[1], err)
+	}
+	return metrics, nil
+}
@@ -59,6 +59,11 @@ type mockContainer struct {
 	factory *mockFactory
 	name    string
 	started bool
+	state   string
+	// cached holds the state last seen by Refresh, and is nil when
+	// the state is unknown. Like the real container, the mock only
+	// sees changes made by SetState after it is refreshed.
+	cached *string
 }
 
 // Name returns the name of the container.
@@ -71,6 +76,8 @@ func (mock *mockContainer) Start(params kvm.StartParams) error {
 		return fmt.Errorf("container is already running")
 	}
 	mock.started = true
+	mock.state = "running"
+	mock.cached = nil
 	mock.factory.notify(Started, mock.name)
 	return nil
 }
@@ -81,12 +88,49 @@ func (mock *mockContainer) Stop() error {
 		return fmt.Errorf("container is not running")
 	}
 	mock.started = false
+	mock.state = "shut off"
+	mock.cached = nil
 	mock.factory.notify(Stopped, mock.name)
 	return nil
 }
 
 func (mock *mockContainer) IsRunning() bool {
-	return mock.started
+	return mock.State() == "running"
+}
+
+// State returns the libvirt state of the container as of the last
+// refresh.
+func (mock *mockContainer) State() string {
+	if mock.cached == nil {
+		mock.Refresh()
+	}
+	return *mock.cached
+}
+
+// SetState changes the libvirt state of a container created by the
+// mock factory, so tests can simulate paused or crashed containers.
+// The change is not seen by the container until it is refreshed.
+// The container is considered running only while the state is
+// "running".
+func SetState(container kvm.Container, state string) {
+	mock := container.(*mockContainer)
+	mock.state = state
+	mock.started = state == "running"
+}
+
+// Metrics returns empty metrics for a running container.
+func (mock *mockContainer) Metrics() (kvm.InstanceMetrics, error) {
+	if !mock.IsRunning() {
+		return kvm.InstanceMetrics{}, fmt.Errorf("container %q is not running", mock.name)
+	}
+	return kvm.InstanceMetrics{}, nil
+}
+
+// Refresh updates the state reported by the container.
+func (mock *mockContainer) Refresh() error {
+	state := mock.state
+	mock.cached = &state
+	return nil
 }
 
 // String returns information about the container.
@@ -76,6 +76,23 @@ func (*MockSuite) TestContainerStoppingRunningStops(c *gc.C) {
 	c.Assert(container.IsRunning(), jc.IsFalse)
 }
 
+func (*MockSuite) TestSetStateSeenAfterRefresh(c *gc.C) {
+	factory := mock.MockFactory()
+	container := factory.New("first")
+	err := container.Start(kvm.StartParams{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(container.State(), gc.Equals, "running")
+
+	mock.SetState(container, "paused")
+	c.Assert(container.State(), gc.Equals, "running")
+	c.Assert(container.IsRunning(), jc.IsTrue)
+
+	err = container.Refresh()
+	c.Assert(err, gc.IsNil)
+	c.Assert(container.State(), gc.Equals, "paused")
+	c.Assert(container.IsRunning(), jc.IsFalse)
+}
+
 func (*MockSuite) TestAddListener(c *gc.C) {
 	listener := make(chan mock.Event)
 	factory := mock.MockFactory()
@@ -168,6 +168,49 @@ func (f *fileStorageWriter) Put(name string, r io.Reader, length int64) error {
 	return utils.ReplaceFile(file.Name(), fullpath)
 }
 
+// Move moves the file with the given old name to the new name,
+// replacing any file already there. The file is renamed atomically
+// where possible, and copied otherwise. It returns a not found error
+// if there is no file with the old name.
+func (f *fileStorageWriter) Move(oldName, newName string) error {
+	if isInternalPath(oldName) || isInternalPath(newName) {
+		return &os.PathError{
+			Op:   "Move",
+			Path: oldName,
+			Err:  os.ErrPermission,
+		}
+	}
+	oldPath := f.fullPath(oldName)
+	fi, err := os.Stat(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errors.NewNotFound(err, "")
+		}
+		return err
+	} else if fi.IsDir() {
+		return errors.NotFoundf("no such file with name %q", oldName)
+	}
+	newPath := f.fullPath(newName)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	if err := utils.ReplaceFile(oldPath, newPath); err == nil {
+		return nil
+	}
+	// The rename failed, perhaps because the paths are on
+	// different filesystems, so copy the file instead.
+	file, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	err = f.Put(newName, file, fi.Size())
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return f.Remove(oldName)
+}
+
 func (f *fileStorageWriter) Remove(name string) error {
 	fullpath := f.fullPath(name)
 	err := os.Remove(fullpath)
@@ -183,6 +183,34 @@ func (s *filestorageSuite) TestRemove(c *gc.C) {
 	c.Assert(err, gc.Not(gc.IsNil))
 }
 
+func (s *filestorageSuite) TestMove(c *gc.C) {
+	oldPath, data := s.createFile(c, "test-file")
+	mover := s.writer.(interface {
+		Move(oldName, newName string) error
+	})
+	err := mover.Move("test-file", "a/b/moved-file")
+	c.Assert(err, gc.IsNil)
+	_, err = os.Stat(oldPath)
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, "a", "b", "moved-file"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(b, gc.DeepEquals, data)
+
+	// Moving onto an existing file replaces it.
+	err = ioutil.WriteFile(filepath.Join(s.dir, "other-file"), []byte("other"), 0644)
+	c.Assert(err, gc.IsNil)
+	err = mover.Move("other-file", "a/b/moved-file")
+	c.Assert(err, gc.IsNil)
+	b, err = ioutil.ReadFile(filepath.Join(s.dir, "a", "b", "moved-file"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(b), gc.Equals, "other")
+
+	err = mover.Move("no-such-file", "whatever")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	err = mover.Move("a/b/moved-file", ".tmp/moved-file")
+	c.Assert(err, jc.Satisfies, os.IsPermission)
+}
+
 func (s *filestorageSuite) TestRemoveAll(c *gc.C) {
 	expectedpath, _ := s.createFile(c, "test-file")
 	err := s.writer.RemoveAll()
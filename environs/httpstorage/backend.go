@@ -4,20 +4,55 @@
 package httpstorage
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
-	"errors"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/juju/errors"
+
 	"github.com/juju/juju/cert"
 	"github.com/juju/juju/environs/storage"
 )
 
+const (
+	// hashDir is the directory in the backend holding the
+	// SHA-256 hashes of stored files, under the files' names.
+	// It is never visible to clients.
+	hashDir = ".hashes"
+
+	// contentTypeDir is the directory in the backend holding
+	// the content types of stored files, as recorded when they
+	// were put. It is never visible to clients.
+	contentTypeDir = ".content-types"
+
+	// hashHeader holds the hex-encoded SHA-256 hash of
+	// a file's contents, as recorded when it was put.
+	hashHeader = "X-Content-Sha256"
+
+	// destinationHeader holds the new name of
+	// a file being moved with a MOVE request.
+	destinationHeader = "Destination"
+)
+
+// mover is implemented by storage backends that
+// can move files more efficiently than by copying.
+type mover interface {
+	Move(oldName, newName string) error
+}
+
 // storageBackend provides HTTP access to a storage object.
 type storageBackend struct {
 	backend storage.Storage
@@ -31,10 +66,33 @@ type storageBackend struct {
 	authkey string
 }
 
+// hashName returns the name of the file holding
+// the SHA-256 hash of the named file.
+func hashName(name string) string {
+	return hashDir + "/" + name
+}
+
+// contentTypeName returns the name of the file holding
+// the content type of the named file.
+func contentTypeName(name string) string {
+	return contentTypeDir + "/" + name
+}
+
+// isInternalPath reports whether the name is in the part
+// of the backend used to store hashes and content types.
+func isInternalPath(name string) bool {
+	for _, dir := range []string{hashDir, contentTypeDir} {
+		if name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // ServeHTTP handles the HTTP requests to the container.
 func (s *storageBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
-	case "PUT", "DELETE":
+	case "PUT", "DELETE", "MOVE":
 		// Don't allow modifying operations if there's an HTTPS backend
 		// to handle that, and ensure the user is authorized/authenticated.
 		if s.httpsPort != 0 || !s.authorized(req) {
@@ -42,6 +100,10 @@ func (s *storageBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
+	if isInternalPath(req.URL.Path[1:]) {
+		http.Error(w, fmt.Sprintf("%q is reserved for internal use", req.URL.Path), http.StatusBadRequest)
+		return
+	}
 	switch req.Method {
 	case "GET":
 		if strings.HasSuffix(req.URL.Path, "*") {
@@ -55,6 +117,8 @@ func (s *storageBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		s.handlePut(w, req)
 	case "DELETE":
 		s.handleDelete(w, req)
+	case "MOVE":
+		s.handleMove(w, req)
 	default:
 		http.Error(w, "method "+req.Method+" is not supported", http.StatusMethodNotAllowed)
 	}
@@ -88,7 +152,9 @@ func hostOnly(host string) (string, error) {
 }
 
 // handleHead returns the HTTPS URL for the specified
-// path in the Location header.
+// path in the Location header if there is an HTTPS backend.
+// Otherwise it returns the size and modification time of
+// the specified storage file.
 func (s *storageBackend) handleHead(w http.ResponseWriter, req *http.Request) {
 	if s.httpsPort != 0 {
 		host, err := hostOnly(req.Host)
@@ -98,13 +164,51 @@ func (s *storageBackend) handleHead(w http.ResponseWriter, req *http.Request) {
 		}
 		url := fmt.Sprintf("https://%s:%d%s", host, s.httpsPort, req.URL.Path)
 		w.Header().Set("Location", url)
-	} else {
+	} else if req.URL.Path == "/" {
 		http.Error(w, "method HEAD is not supported", http.StatusMethodNotAllowed)
 		return
+	} else {
+		size, modTime, err := s.stat(req.URL.Path[1:])
+		if errors.IsNotFound(err) {
+			http.Error(w, fmt.Sprint(err), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		if !modTime.IsZero() {
+			w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+		}
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// stat returns the size and, if known, the modification
+// time of the named file.
+func (s *storageBackend) stat(name string) (size int64, modTime time.Time, err error) {
+	readcloser, err := s.backend.Get(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errors.NotFoundf("file %q", name)
+		}
+		return 0, time.Time{}, err
+	}
+	defer readcloser.Close()
+	// Files from a file storage can report their details,
+	// anything else is read to find its size.
+	if f, ok := readcloser.(interface {
+		Stat() (os.FileInfo, error)
+	}); ok {
+		if info, err := f.Stat(); err == nil {
+			return info.Size(), info.ModTime(), nil
+		}
+	}
+	size, err = io.Copy(ioutil.Discard, readcloser)
+	return size, time.Time{}, err
+}
+
 // handleGet returns a storage file to the client.
 func (s *storageBackend) handleGet(w http.ResponseWriter, req *http.Request) {
 	readcloser, err := s.backend.Get(req.URL.Path[1:])
@@ -118,19 +222,88 @@ func (s *storageBackend) handleGet(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Write(data)
+	if hash := s.storedHash(req.URL.Path[1:]); hash != "" {
+		w.Header().Set(hashHeader, hash)
+	}
+	contentType := s.storedContentType(req.URL.Path[1:])
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Vary", "Accept-Encoding")
+	// Compress the contents if the client accepts it, unless a range
+	// was requested or they are compressed already.
+	if req.Header.Get("Range") == "" && acceptsGzip(req) && !compressedContentTypes[contentType] {
+		w.Header().Set("Content-Encoding", "gzip")
+		gzw := gzip.NewWriter(w)
+		defer gzw.Close()
+		gzw.Write(data)
+		return
+	}
+	// ServeContent honours any Range header in the request.
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(data))
+}
+
+// compressedContentTypes holds the content types of
+// files that are not worth compressing again.
+var compressedContentTypes = map[string]bool{
+	"application/gzip":   true,
+	"application/x-gzip": true,
+	"application/zip":    true,
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding
+// header allows a gzip-encoded response. A gzip coding with
+// a quality value of zero is explicitly refused.
+func acceptsGzip(req *http.Request) bool {
+	for _, coding := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(coding, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[len("q="):], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
 }
 
 // handleList returns the file names in the storage to the client.
 func (s *storageBackend) handleList(w http.ResponseWriter, req *http.Request) {
 	prefix := req.URL.Path
 	prefix = prefix[1 : len(prefix)-1] // drop the leading '/' and trailing '*'
-	names, err := s.backend.List(prefix)
+	allNames, err := s.backend.List(prefix)
 	if err != nil {
 		http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
 		return
 	}
+	query := req.URL.Query()
+	after := query.Get("after")
+	limit := 0
+	if limitParam := query.Get("limit"); limitParam != "" {
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", limitParam), http.StatusBadRequest)
+			return
+		}
+	}
+	sort.Strings(allNames)
+	names := make([]string, 0, len(allNames))
+	for _, name := range allNames {
+		// Skip names up to the marker, and hide
+		// the files holding stored hashes.
+		if isInternalPath(name) || name <= after {
+			continue
+		}
+		if limit > 0 && len(names) == limit {
+			break
+		}
+		names = append(names, name)
+	}
 	data := []byte(strings.Join(names, "\n"))
 	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Write(data)
@@ -142,21 +315,95 @@ func (s *storageBackend) handlePut(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "missing or invalid Content-Length header", http.StatusInternalServerError)
 		return
 	}
-	err := s.backend.Put(req.URL.Path[1:], req.Body, req.ContentLength)
+	name := req.URL.Path[1:]
+	hasher := sha256.New()
+	var head headWriter
+	body := io.TeeReader(req.Body, io.MultiWriter(hasher, &head))
+	err := s.backend.Put(name, body, req.ContentLength)
 	if err != nil {
 		http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
 		return
 	}
+	// The client library gives no meaningful content type
+	// for most files, so detect one from their contents.
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(head)
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	err = s.backend.Put(hashName(name), strings.NewReader(hash), int64(len(hash)))
+	if err == nil {
+		err = s.backend.Put(contentTypeName(name), strings.NewReader(contentType), int64(len(contentType)))
+	}
+	if err != nil {
+		// Don't leave the file in place without its metadata.
+		for _, name := range []string{name, hashName(name)} {
+			if err := s.backend.Remove(name); err != nil {
+				logger.Warningf("cannot remove %q after failing to store metadata: %v", name, err)
+			}
+		}
+		http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(hashHeader, hash)
 	w.WriteHeader(http.StatusCreated)
 }
 
+// headWriter records the first bytes written to it, as many
+// as are used by http.DetectContentType.
+type headWriter []byte
+
+func (w *headWriter) Write(buf []byte) (int, error) {
+	if n := 512 - len(*w); n > 0 {
+		if n > len(buf) {
+			n = len(buf)
+		}
+		*w = append(*w, buf[:n]...)
+	}
+	return len(buf), nil
+}
+
+// storedHash returns the SHA-256 hash recorded when the
+// named file was put, or "" if there is none.
+func (s *storageBackend) storedHash(name string) string {
+	return s.readInternal(hashName(name))
+}
+
+// storedContentType returns the content type recorded when
+// the named file was put, or "" if there is none.
+func (s *storageBackend) storedContentType(name string) string {
+	return s.readInternal(contentTypeName(name))
+}
+
+// readInternal returns the contents of the named internal
+// file, or "" if it cannot be read.
+func (s *storageBackend) readInternal(name string) string {
+	r, err := s.backend.Get(name)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
 // handleDelete removes a file from the storage.
 func (s *storageBackend) handleDelete(w http.ResponseWriter, req *http.Request) {
 	if !s.authorized(req) {
 		http.Error(w, "unauthorized access", http.StatusUnauthorized)
 		return
 	}
-	err := s.backend.Remove(req.URL.Path[1:])
+	name := req.URL.Path[1:]
+	err := s.backend.Remove(name)
+	if err == nil {
+		err = s.backend.Remove(hashName(name))
+	}
+	if err == nil {
+		err = s.backend.Remove(contentTypeName(name))
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
 		return
@@ -164,6 +411,72 @@ func (s *storageBackend) handleDelete(w http.ResponseWriter, req *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleMove moves a file in the storage to the
+// name given in the Destination header.
+func (s *storageBackend) handleMove(w http.ResponseWriter, req *http.Request) {
+	oldName := req.URL.Path[1:]
+	newName := req.Header.Get(destinationHeader)
+	if newName == "" {
+		http.Error(w, "missing Destination header", http.StatusBadRequest)
+		return
+	}
+	if isInternalPath(newName) {
+		http.Error(w, fmt.Sprintf("%q is reserved for internal use", newName), http.StatusBadRequest)
+		return
+	}
+	hasHash := s.storedHash(oldName) != ""
+	hasContentType := s.storedContentType(oldName) != ""
+	if err := s.move(oldName, newName); err != nil {
+		status := http.StatusInternalServerError
+		if errors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, fmt.Sprint(err), status)
+		return
+	}
+	// Keep any stored hash and content type with the file they describe.
+	err := s.moveInternal(hashName(oldName), hashName(newName), hasHash)
+	if err == nil {
+		err = s.moveInternal(contentTypeName(oldName), contentTypeName(newName), hasContentType)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// moveInternal moves an internal file along with the file it
+// describes, or removes any stale one at the new name if the
+// file had none.
+func (s *storageBackend) moveInternal(oldName, newName string, exists bool) error {
+	if exists {
+		return s.move(oldName, newName)
+	}
+	return s.backend.Remove(newName)
+}
+
+// move moves a file in the backend, using the backend's own
+// Move method if it has one, and copying the file otherwise.
+func (s *storageBackend) move(oldName, newName string) error {
+	if m, ok := s.backend.(mover); ok {
+		return m.Move(oldName, newName)
+	}
+	r, err := s.backend.Get(oldName)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	if err := s.backend.Put(newName, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	return s.backend.Remove(oldName)
+}
+
 // Serve runs a storage server on the given network address, relaying
 // requests to the given storage implementation. It returns the network
 // listener. This can then be attached to with Client.
@@ -5,6 +5,7 @@ package httpstorage_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -206,6 +207,97 @@ func testGet(c *gc.C, client *http.Client, url string) {
 	}
 }
 
+func (s *backendSuite) TestGetRange(c *gc.C) {
+	listener, url, dataDir := startServer(c)
+	defer listener.Close()
+	createTestData(c, dataDir)
+
+	req, err := http.NewRequest("GET", url+"foo", nil)
+	c.Assert(err, gc.IsNil)
+	req.Header.Set("Range", "bytes=8-11")
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, gc.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusPartialContent)
+	data, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "file")
+}
+
+func (s *backendSuite) TestGetGzip(c *gc.C) {
+	listener, url, dataDir := startServer(c)
+	defer listener.Close()
+	createTestData(c, dataDir)
+	var compressed bytes.Buffer
+	gzw := gzip.NewWriter(&compressed)
+	_, err := gzw.Write([]byte("already compressed"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(gzw.Close(), gc.IsNil)
+
+	// Decompress responses ourselves, to see what the server sent.
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	put := func(name, contentType string, data []byte) {
+		req, err := http.NewRequest("PUT", url+name, bytes.NewReader(data))
+		c.Assert(err, gc.IsNil)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := client.Do(req)
+		c.Assert(err, gc.IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, gc.Equals, http.StatusCreated)
+	}
+	// The content type of each file is recorded when it is put, from
+	// the request if it is meaningful and from the contents otherwise.
+	put("archive.tgz", "application/octet-stream", compressed.Bytes())
+	put("archive.zip", "application/zip", []byte("not really a zip"))
+	get := func(name, acceptEncoding string) *http.Response {
+		req, err := http.NewRequest("GET", url+name, nil)
+		c.Assert(err, gc.IsNil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp, err := client.Do(req)
+		c.Assert(err, gc.IsNil)
+		c.Assert(resp.StatusCode, gc.Equals, http.StatusOK)
+		return resp
+	}
+
+	resp := get("foo", "deflate, gzip;q=0.8")
+	defer resp.Body.Close()
+	c.Assert(resp.Header.Get("Content-Encoding"), gc.Equals, "gzip")
+	c.Assert(resp.Header.Get("Content-Type"), gc.Equals, "application/octet-stream")
+	gzr, err := gzip.NewReader(resp.Body)
+	c.Assert(err, gc.IsNil)
+	data, err := ioutil.ReadAll(gzr)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "this is file 'foo'")
+
+	// Contents are not compressed unless the client accepts it.
+	for _, acceptEncoding := range []string{"", "gzip;q=0", "deflate, gzip; q=0.0"} {
+		c.Logf("Accept-Encoding: %q", acceptEncoding)
+		resp = get("foo", acceptEncoding)
+		defer resp.Body.Close()
+		c.Assert(resp.Header.Get("Content-Encoding"), gc.Equals, "")
+		data, err = ioutil.ReadAll(resp.Body)
+		c.Assert(err, gc.IsNil)
+		c.Assert(string(data), gc.Equals, "this is file 'foo'")
+	}
+
+	// Contents that are already compressed are sent as they are.
+	resp = get("archive.tgz", "gzip")
+	defer resp.Body.Close()
+	c.Assert(resp.Header.Get("Content-Encoding"), gc.Equals, "")
+	c.Assert(resp.Header.Get("Content-Type"), gc.Equals, "application/x-gzip")
+	data, err = ioutil.ReadAll(resp.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(data, gc.DeepEquals, compressed.Bytes())
+
+	resp = get("archive.zip", "gzip")
+	defer resp.Body.Close()
+	c.Assert(resp.Header.Get("Content-Encoding"), gc.Equals, "")
+	c.Assert(resp.Header.Get("Content-Type"), gc.Equals, "application/zip")
+	data, err = ioutil.ReadAll(resp.Body)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "not really a zip")
+}
+
 var listTests = []testCase{
 	{
 		// List with a full filename.
@@ -4,16 +4,22 @@
 package httpstorage
 
 import (
+	"compress/gzip"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
@@ -24,7 +30,82 @@ import (
 
 var logger = loggo.GetLogger("juju.environs.httpstorage")
 
-// storage implements the storage.Storage interface.
+// Storage is implemented by the storage objects returned by
+// Client and ClientTLS. It extends storage.Storage with operations
+// supported by the storage server.
+type Storage interface {
+	storage.Storage
+
+	// GetRange opens the given storage file and returns a ReadCloser
+	// that reads at most length bytes from it, starting at offset
+	// start. If length is negative, the file is read to its end; if
+	// it is zero, an empty reader is returned without reading the
+	// file. Otherwise it is an error if start is beyond the end of
+	// the file.
+	GetRange(name string, start, length int64) (io.ReadCloser, error)
+
+	// GetVerified opens the given storage file and returns a
+	// ReadCloser that verifies the file's contents have the given
+	// hex-encoded SHA-256 hash. If expectedHash is empty, the hash
+	// recorded by the server when the file was put is used. Reading
+	// the last of the file's contents returns a *ChecksumMismatchError
+	// if the contents do not match.
+	GetVerified(name string, expectedHash string) (io.ReadCloser, error)
+
+	// ListN lists, in alphabetical order, at most limit names in the
+	// storage with the given prefix that sort after the given marker.
+	// If limit is zero or negative, all names after the marker are
+	// listed. Callers can paginate by passing the last name of one
+	// page as the marker for the next.
+	ListN(prefix string, after string, limit int) ([]string, error)
+
+	// Move moves the given storage file to the new name, replacing
+	// any file already there. The server renames the file atomically
+	// where possible. If the old name does not exist, it returns a
+	// *NotFoundError.
+	Move(oldName, newName string) error
+
+	// RemoveAllWithPrefix removes all files in the storage whose
+	// names have the given prefix, leaving all others untouched.
+	// Files removed concurrently by other clients are ignored. An
+	// empty prefix removes all files, as RemoveAll does.
+	RemoveAllWithPrefix(prefix string) error
+
+	// Stat returns information about the given storage file,
+	// without reading its contents. If the name does not exist,
+	// it returns a *NotFoundError.
+	Stat(name string) (StorageObjectInfo, error)
+}
+
+// StorageObjectInfo holds information about a storage file.
+type StorageObjectInfo struct {
+	// Size holds the size of the file in bytes.
+	Size int64
+
+	// LastModified holds the time the file was last
+	// modified. It is zero if the time is not known.
+	LastModified time.Time
+}
+
+// ChecksumMismatchError is returned when the contents of a
+// file read with GetVerified do not match the expected hash.
+type ChecksumMismatchError struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %q: expected %s, got %s", e.Name, e.Expected, e.Actual)
+}
+
+// IsChecksumMismatch reports whether err is a *ChecksumMismatchError.
+func IsChecksumMismatch(err error) bool {
+	_, ok := err.(*ChecksumMismatchError)
+	return ok
+}
+
+// storage implements the Storage interface.
 type localStorage struct {
 	addr   string
 	client *http.Client
@@ -37,7 +118,7 @@ type localStorage struct {
 
 // Client returns a storage object that will talk to the
 // storage server at the given network address (see Serve)
-func Client(addr string) storage.Storage {
+func Client(addr string) Storage {
 	return &localStorage{
 		addr:   addr,
 		client: utils.GetValidatingHTTPClient(),
@@ -48,7 +129,7 @@ func Client(addr string) storage.Storage {
 // storage server at the given network address (see Serve),
 // using TLS. The client is given an authentication key,
 // which the server will verify for Put and Remove* operations.
-func ClientTLS(addr string, caCertPEM string, authkey string) (storage.Storage, error) {
+func ClientTLS(addr string, caCertPEM string, authkey string) (Storage, error) {
 	logger.Debugf("using https storage at %q", addr)
 	caCerts := x509.NewCertPool()
 	if !caCerts.AppendCertsFromPEM([]byte(caCertPEM)) {
@@ -64,7 +145,7 @@ func ClientTLS(addr string, caCertPEM string, authkey string) (storage.Storage,
 }
 
 func (s *localStorage) getHTTPSBaseURL() (string, error) {
-	url, _ := s.URL("") // never fails
+	url := s.fileURL("")
 	resp, err := s.client.Head(url)
 	if err != nil {
 		return "", err
@@ -86,31 +167,165 @@ func (s *localStorage) getHTTPSBaseURL() (string, error) {
 // exist, it should return a *NotFoundError.
 func (s *localStorage) Get(name string) (io.ReadCloser, error) {
 	logger.Debugf("getting %q from storage", name)
-	url, err := s.URL(name)
+	url := s.fileURL(name)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := s.client.Get(url)
+	// Ask for compressed contents, to save bandwidth on
+	// slow links; they are decompressed transparently.
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, errors.NotFoundf("file %q", name)
 	}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("cannot decompress %q: %v", name, err)
+		}
+		return &readCloser{gzr, resp.Body}, nil
+	}
 	return resp.Body, nil
 }
 
+// GetRange is specified in the Storage interface. The range
+// is requested from the server with a Range header; if the server
+// replies with the whole file, the range is extracted locally.
+func (s *localStorage) GetRange(name string, start, length int64) (io.ReadCloser, error) {
+	logger.Debugf("getting %q (start %d, len %d) from storage", name, start, length)
+	if start < 0 {
+		return nil, fmt.Errorf("invalid range start %d", start)
+	}
+	if length == 0 {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	url := s.fileURL(name)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, start+length-1))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// The server does not support ranges, so
+		// skip to the start of the range ourselves.
+		if n, err := io.CopyN(ioutil.Discard, resp.Body, start); err != nil {
+			resp.Body.Close()
+			if err == io.EOF {
+				return nil, fmt.Errorf("range start %d is beyond end of file %q (size %d)", start, name, n)
+			}
+			return nil, err
+		}
+		if length < 0 {
+			return resp.Body, nil
+		}
+		return &readCloser{io.LimitReader(resp.Body, length), resp.Body}, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		return nil, fmt.Errorf("range start %d is beyond end of file %q", start, name)
+	}
+	resp.Body.Close()
+	return nil, errors.NotFoundf("file %q", name)
+}
+
+// GetVerified is specified in the Storage interface.
+func (s *localStorage) GetVerified(name string, expectedHash string) (io.ReadCloser, error) {
+	logger.Debugf("getting %q from storage, verifying contents", name)
+	url := s.fileURL(name)
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.NotFoundf("file %q", name)
+	}
+	if expectedHash == "" {
+		expectedHash = resp.Header.Get(hashHeader)
+		if expectedHash == "" {
+			resp.Body.Close()
+			return nil, fmt.Errorf("no hash recorded for file %q", name)
+		}
+	}
+	return &verifyingReadCloser{
+		ReadCloser: resp.Body,
+		name:       name,
+		expected:   expectedHash,
+		hasher:     sha256.New(),
+	}, nil
+}
+
+// verifyingReadCloser hashes the contents read from its
+// ReadCloser, and checks the hash once they have all been read.
+type verifyingReadCloser struct {
+	io.ReadCloser
+	name     string
+	expected string
+	hasher   hash.Hash
+}
+
+func (r *verifyingReadCloser) Read(buf []byte) (int, error) {
+	n, err := r.ReadCloser.Read(buf)
+	r.hasher.Write(buf[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(r.hasher.Sum(nil)); actual != r.expected {
+			return n, &ChecksumMismatchError{
+				Name:     r.name,
+				Expected: r.expected,
+				Actual:   actual,
+			}
+		}
+	}
+	return n, err
+}
+
+// readCloser reads from a Reader wrapping
+// the underlying Closer, closing it when done.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
 // List lists all names in the storage with the given prefix, in
 // alphabetical order. The names in the storage are considered
 // to be in a flat namespace, so the prefix may include slashes
 // and the names returned are the full names for the matching
 // entries.
 func (s *localStorage) List(prefix string) ([]string, error) {
-	url, err := s.URL(prefix)
-	if err != nil {
-		return nil, err
+	return s.ListN(prefix, "", 0)
+}
+
+// ListN is specified in the Storage interface.
+func (s *localStorage) ListN(prefix string, after string, limit int) ([]string, error) {
+	query := url.Values{}
+	if after != "" {
+		query.Set("after", after)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	url := s.fileURL(prefix)
+	url += "*"
+	if len(query) > 0 {
+		url += "?" + query.Encode()
 	}
-	resp, err := s.client.Get(url + "*")
+	resp, err := s.client.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -137,24 +352,95 @@ func (s *localStorage) List(prefix string) ([]string, error) {
 }
 
 // URL returns a URL that can be used to access the given storage file.
+// If the file does not exist, it returns a *NotFoundError.
 func (s *localStorage) URL(name string) (string, error) {
-	return fmt.Sprintf("http://%s/%s", s.addr, name), nil
+	url := s.fileURL(name)
+	if name == "" {
+		return url, nil
+	}
+	// Request as little of the file as possible
+	// to find out whether it exists.
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		// The file exists; the range cannot be
+		// satisfied only if the file is empty.
+		return url, nil
+	case http.StatusNotFound:
+		return "", errors.NotFoundf("file %q", name)
+	}
+	return "", fmt.Errorf("%d %s", resp.StatusCode, resp.Status)
+}
+
+// fileURL returns the URL of the given storage file,
+// without checking whether it exists.
+func (s *localStorage) fileURL(name string) string {
+	return fmt.Sprintf("http://%s/%s", s.addr, name)
 }
 
 // modURL returns a URL that can be used to modify the given storage file.
 func (s *localStorage) modURL(name string) (string, error) {
 	if s.authkey == "" {
-		return s.URL(name)
+		return s.fileURL(name), nil
 	}
+	baseURL, err := s.httpsBase()
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("authkey", s.authkey)
+	return fmt.Sprintf("%s%s?%s", baseURL, name, v.Encode()), nil
+}
+
+// httpsBase returns the base URL of the HTTPS storage
+// server, asking the storage server for it the first time.
+func (s *localStorage) httpsBase() (string, error) {
 	s.httpsBaseURLOnce.Do(func() {
 		s.httpsBaseURL, s.httpsBaseURLError = s.getHTTPSBaseURL()
 	})
-	if s.httpsBaseURLError != nil {
-		return "", s.httpsBaseURLError
+	return s.httpsBaseURL, s.httpsBaseURLError
+}
+
+// Stat is specified in the Storage interface.
+func (s *localStorage) Stat(name string) (StorageObjectInfo, error) {
+	url := s.fileURL(name)
+	if s.authkey != "" {
+		// When there is an HTTPS server, HEAD requests
+		// to the plain server just return its URL.
+		baseURL, err := s.httpsBase()
+		if err != nil {
+			return StorageObjectInfo{}, err
+		}
+		url = baseURL + name
 	}
-	v := url.Values{}
-	v.Set("authkey", s.authkey)
-	return fmt.Sprintf("%s%s?%s", s.httpsBaseURL, name, v.Encode()), nil
+	resp, err := s.client.Head(url)
+	if err != nil {
+		return StorageObjectInfo{}, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return StorageObjectInfo{}, errors.NotFoundf("file %q", name)
+	default:
+		return StorageObjectInfo{}, fmt.Errorf("%d %s", resp.StatusCode, resp.Status)
+	}
+	info := StorageObjectInfo{Size: resp.ContentLength}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		if info.LastModified, err = http.ParseTime(lastModified); err != nil {
+			return StorageObjectInfo{}, fmt.Errorf("invalid Last-Modified header %q: %v", lastModified, err)
+		}
+	}
+	return info, nil
 }
 
 // DefaultConsistencyStrategy is specified in the StorageReader interface.
@@ -220,6 +506,51 @@ func (s *localStorage) Remove(name string) error {
 	return nil
 }
 
+// Move is specified in the Storage interface.
+func (s *localStorage) Move(oldName, newName string) error {
+	logger.Debugf("moving %q to %q in storage", oldName, newName)
+	url, err := s.modURL(oldName)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("MOVE", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(destinationHeader, newName)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return errors.NotFoundf("file %q", oldName)
+	}
+	return fmt.Errorf("%d %s", resp.StatusCode, resp.Status)
+}
+
 func (s *localStorage) RemoveAll() error {
 	return storage.RemoveAll(s)
 }
+
+// RemoveAllWithPrefix is specified in the Storage interface.
+func (s *localStorage) RemoveAllWithPrefix(prefix string) error {
+	if prefix == "" {
+		return s.RemoveAll()
+	}
+	names, err := s.List(prefix)
+	if err != nil {
+		return fmt.Errorf("unable to list files for deletion: %v", err)
+	}
+	for _, name := range names {
+		// Remove does not fail if the file has
+		// already been removed by someone else.
+		if err := s.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,16 +5,22 @@ package httpstorage_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	gc "launchpad.net/gocheck"
 
+	"github.com/juju/juju/environs/filestorage"
 	"github.com/juju/juju/environs/httpstorage"
 	"github.com/juju/juju/environs/storage"
 	coretesting "github.com/juju/juju/testing"
@@ -41,6 +47,7 @@ func (s *storageSuite) TestClientTLS(c *gc.C) {
 	// Put, Remove and RemoveAll should all succeed.
 	checkPutFile(c, stor, "filenamethesecond", data)
 	checkFileHasContents(c, stor, "filenamethesecond", data)
+	checkStat(c, stor, "filenamethesecond", data)
 	c.Assert(stor.Remove("filenamethesecond"), gc.IsNil)
 	c.Assert(stor.RemoveAll(), gc.IsNil)
 }
@@ -129,6 +136,305 @@ func (s *storageSuite) TestPersistence(c *gc.C) {
 	checkRemoveAll(c, storage2)
 }
 
+func (s *storageSuite) TestStat(c *gc.C) {
+	listener, _, _ := startServer(c)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+	for _, contents := range []string{"some contents", ""} {
+		name := fmt.Sprintf("file-%d", len(contents))
+		checkPutFile(c, stor, name, []byte(contents))
+		checkStat(c, stor, name, []byte(contents))
+	}
+	_, err := stor.Stat("missing")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func checkStat(c *gc.C, stor httpstorage.Storage, name string, contents []byte) {
+	info, err := stor.Stat(name)
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Size, gc.Equals, int64(len(contents)))
+	c.Assert(info.LastModified.IsZero(), jc.IsFalse)
+	c.Assert(time.Since(info.LastModified) < time.Minute, jc.IsTrue)
+}
+
+func (s *storageSuite) TestRemoveAllWithPrefix(c *gc.C) {
+	listener, _, _ := startServer(c)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+	for _, name := range []string{"aa", "zz", "zzz/aa", "zzz/bb", "zzz/cc/dd"} {
+		checkPutFile(c, stor, name, []byte(name))
+	}
+	err := stor.RemoveAllWithPrefix("zzz/")
+	c.Assert(err, gc.IsNil)
+	checkList(c, stor, "", []string{"aa", "zz"})
+
+	// Nothing matching the prefix is fine.
+	err = stor.RemoveAllWithPrefix("nothing/")
+	c.Assert(err, gc.IsNil)
+	checkList(c, stor, "", []string{"aa", "zz"})
+
+	// An empty prefix removes everything.
+	err = stor.RemoveAllWithPrefix("")
+	c.Assert(err, gc.IsNil)
+	checkList(c, stor, "", nil)
+}
+
+func (s *storageSuite) TestURL(c *gc.C) {
+	listener, _, _ := startServer(c)
+	defer listener.Close()
+	addr := listener.Addr().String()
+	stor := httpstorage.Client(addr)
+	checkPutFile(c, stor, "file", []byte("contents"))
+	checkPutFile(c, stor, "empty", nil)
+
+	url, err := stor.URL("file")
+	c.Assert(err, gc.IsNil)
+	c.Assert(url, gc.Equals, "http://"+addr+"/file")
+	url, err = stor.URL("empty")
+	c.Assert(err, gc.IsNil)
+	c.Assert(url, gc.Equals, "http://"+addr+"/empty")
+
+	url, err = stor.URL("missing")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `file "missing" not found`)
+	c.Assert(url, gc.Equals, "")
+}
+
+func (s *storageSuite) TestGetRange(c *gc.C) {
+	listener, _, _ := startServer(c)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+	checkPutFile(c, stor, "file", []byte("0123456789"))
+	checkGetRange(c, stor)
+
+	_, err := stor.GetRange("missing", 0, 1)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *storageSuite) TestGetRangeWithoutServerSupport(c *gc.C) {
+	// A server that ignores Range headers and always
+	// replies with the whole file.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+	stor := httpstorage.Client(server.Listener.Addr().String())
+	checkGetRange(c, stor)
+}
+
+func (s *storageSuite) TestGetRangeZeroLength(c *gc.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Errorf("unexpected request for %q", req.URL.Path)
+	}))
+	defer server.Close()
+	stor := httpstorage.Client(server.Listener.Addr().String())
+	r, err := stor.GetRange("file", 3, 0)
+	c.Assert(err, gc.IsNil)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	c.Assert(err, gc.IsNil)
+	c.Assert(data, gc.HasLen, 0)
+}
+
+func (s *storageSuite) TestGetVerified(c *gc.C) {
+	listener, _, storageDir := startServer(c)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+	contents := []byte("some contents")
+	checkPutFile(c, stor, "file", contents)
+	hash := sha256.Sum256(contents)
+	expectedHash := hex.EncodeToString(hash[:])
+
+	// The stored hash is hidden from listings.
+	checkList(c, stor, "", []string{"file"})
+
+	// Contents can be verified against the hash recorded
+	// by the server, or one given by the caller.
+	for _, verifyHash := range []string{"", expectedHash} {
+		r, err := stor.GetVerified("file", verifyHash)
+		c.Assert(err, gc.IsNil)
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		c.Assert(err, gc.IsNil)
+		c.Assert(data, gc.DeepEquals, contents)
+	}
+
+	r, err := stor.GetVerified("file", "deadbeef")
+	c.Assert(err, gc.IsNil)
+	_, err = ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, gc.ErrorMatches, `checksum mismatch for "file": expected deadbeef, got `+expectedHash)
+	c.Assert(err, jc.Satisfies, httpstorage.IsChecksumMismatch)
+
+	// Corruption of the stored file is detected.
+	err = ioutil.WriteFile(filepath.Join(storageDir, "file"), []byte("corrupted"), 0644)
+	c.Assert(err, gc.IsNil)
+	r, err = stor.GetVerified("file", "")
+	c.Assert(err, gc.IsNil)
+	_, err = ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, jc.Satisfies, httpstorage.IsChecksumMismatch)
+
+	// Files with no recorded hash can only be verified
+	// against a given hash.
+	err = ioutil.WriteFile(filepath.Join(storageDir, "unhashed"), contents, 0644)
+	c.Assert(err, gc.IsNil)
+	_, err = stor.GetVerified("unhashed", "")
+	c.Assert(err, gc.ErrorMatches, `no hash recorded for file "unhashed"`)
+
+	_, err = stor.GetVerified("missing", expectedHash)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	// Removing the file removes its hash.
+	c.Assert(stor.Remove("file"), gc.IsNil)
+	_, err = os.Stat(filepath.Join(storageDir, ".hashes", "file"))
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
+
+func (s *storageSuite) TestStoredHashesAreHidden(c *gc.C) {
+	listener, _, _ := startServer(c)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+
+	// Files named like hashes are ordinary files, and
+	// do not disturb the hashes of other files.
+	checkPutFile(c, stor, "file", []byte("contents"))
+	checkPutFile(c, stor, "file.sha256", []byte("not a hash"))
+	checkList(c, stor, "", []string{"file", "file.sha256"})
+	checkFileHasContents(c, stor, "file.sha256", []byte("not a hash"))
+	r, err := stor.GetVerified("file", "")
+	c.Assert(err, gc.IsNil)
+	_, err = ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, gc.IsNil)
+	c.Assert(stor.Move("file.sha256", "other.sha256"), gc.IsNil)
+	checkList(c, stor, "", []string{"file", "other.sha256"})
+
+	// The stored hashes themselves are not accessible.
+	err = putFile(c, stor, ".hashes/file", []byte("deadbeef"))
+	c.Assert(err, gc.ErrorMatches, `400 .*`)
+	_, err = storage.Get(stor, ".hashes/file")
+	c.Assert(err, gc.NotNil)
+	err = stor.Move("file", ".hashes/other")
+	c.Assert(err, gc.NotNil)
+	checkList(c, stor, ".hashes", nil)
+
+	// Neither are the stored content types.
+	_, err = storage.Get(stor, ".content-types/file")
+	c.Assert(err, gc.NotNil)
+	err = stor.Move("file", ".content-types/other")
+	c.Assert(err, gc.NotNil)
+	checkList(c, stor, ".content-types", nil)
+}
+
+func (s *storageSuite) TestMove(c *gc.C) {
+	listener, _, _ := startServer(c)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+	checkMove(c, stor)
+}
+
+func (s *storageSuite) TestMoveWithoutBackendSupport(c *gc.C) {
+	// Hide the file storage's Move method, so
+	// the server has to copy files instead.
+	embedded, err := filestorage.NewFileStorageWriter(c.MkDir())
+	c.Assert(err, gc.IsNil)
+	listener, err := httpstorage.Serve("localhost:0", struct{ storage.Storage }{embedded})
+	c.Assert(err, gc.IsNil)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+	checkMove(c, stor)
+}
+
+func checkMove(c *gc.C, stor httpstorage.Storage) {
+	checkPutFile(c, stor, "old", []byte("old contents"))
+	checkPutFile(c, stor, "existing", []byte("existing contents"))
+
+	err := stor.Move("old", "new/name")
+	c.Assert(err, gc.IsNil)
+	checkFileDoesNotExist(c, stor, "old")
+	checkFileHasContents(c, stor, "new/name", []byte("old contents"))
+	// The recorded hash moves with the file.
+	r, err := stor.GetVerified("new/name", "")
+	c.Assert(err, gc.IsNil)
+	_, err = ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(err, gc.IsNil)
+
+	// Moving onto an existing name overwrites it.
+	err = stor.Move("new/name", "existing")
+	c.Assert(err, gc.IsNil)
+	checkFileHasContents(c, stor, "existing", []byte("old contents"))
+	checkList(c, stor, "", []string{"existing"})
+
+	err = stor.Move("missing", "elsewhere")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func checkGetRange(c *gc.C, stor httpstorage.Storage) {
+	for i, test := range []struct {
+		start, length int64
+		expect        string
+	}{
+		{0, 10, "0123456789"},
+		{2, 3, "234"},
+		{5, -1, "56789"},
+		{8, 5, "89"},
+	} {
+		c.Logf("test %d: start %d length %d", i, test.start, test.length)
+		r, err := stor.GetRange("file", test.start, test.length)
+		c.Assert(err, gc.IsNil)
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		c.Assert(err, gc.IsNil)
+		c.Assert(string(data), gc.Equals, test.expect)
+	}
+	_, err := stor.GetRange("file", 20, 1)
+	c.Assert(err, gc.ErrorMatches, `range start 20 is beyond end of file "file".*`)
+}
+
+func (s *storageSuite) TestListN(c *gc.C) {
+	listener, _, _ := startServer(c)
+	defer listener.Close()
+	stor := httpstorage.Client(listener.Addr().String())
+	names := []string{"aa", "zzz/aa", "zzz/bb", "zzz/cc"}
+	for _, name := range names {
+		checkPutFile(c, stor, name, []byte(name))
+	}
+	for i, test := range []struct {
+		prefix string
+		after  string
+		limit  int
+		expect []string
+	}{
+		{"", "", 0, names},
+		{"", "", 2, []string{"aa", "zzz/aa"}},
+		{"", "zzz/aa", 2, []string{"zzz/bb", "zzz/cc"}},
+		{"", "zzz/bb", 0, []string{"zzz/cc"}},
+		{"zzz/", "", 1, []string{"zzz/aa"}},
+		{"zzz/", "zzz/cc", 1, nil},
+	} {
+		c.Logf("test %d: prefix %q after %q limit %d", i, test.prefix, test.after, test.limit)
+		found, err := stor.ListN(test.prefix, test.after, test.limit)
+		c.Assert(err, gc.IsNil)
+		c.Assert(found, gc.DeepEquals, test.expect)
+	}
+
+	// Paginating visits every name once.
+	var all []string
+	after := ""
+	for {
+		page, err := stor.ListN("", after, 3)
+		c.Assert(err, gc.IsNil)
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+		after = page[len(page)-1]
+	}
+	c.Assert(all, gc.DeepEquals, names)
+}
+
 func checkList(c *gc.C, stor storage.StorageReader, prefix string, names []string) {
 	lnames, err := storage.List(stor, prefix)
 	c.Assert(err, gc.IsNil)
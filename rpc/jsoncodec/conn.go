@@ -2,6 +2,8 @@ package jsoncodec
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net"
 
 	"code.google.com/p/go.net/websocket"
@@ -13,6 +15,72 @@ func NewWebsocket(conn *websocket.Conn) *Codec {
 	return New(wsJSONConn{conn})
 }
 
+// ErrMessageTooLarge is returned when a received message exceeds
+// the maximum size set with NewWebsocketWithMaxSize.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// NewWebsocketWithMaxSize is like NewWebsocket except that receiving a
+// message larger than maxSize bytes fails with ErrMessageTooLarge.
+// The remainder of an oversized message is not read, so the
+// connection is unusable after such an error and should be closed.
+func NewWebsocketWithMaxSize(conn *websocket.Conn, maxSize int64) *Codec {
+	r := &messageLimitReader{
+		r:   conn,
+		max: maxSize,
+	}
+	return New(&wsLimitedJSONConn{
+		wsJSONConn: wsJSONConn{conn},
+		reader:     r,
+		dec:        json.NewDecoder(r),
+	})
+}
+
+// wsLimitedJSONConn is a JSONConn that limits the size of the
+// messages it receives. Each message is sent as a single websocket
+// frame, but as websocket.Conn.Read does not expose frame
+// boundaries, messages are read with a streaming decoder.
+type wsLimitedJSONConn struct {
+	wsJSONConn
+	reader *messageLimitReader
+	dec    *json.Decoder
+}
+
+func (conn *wsLimitedJSONConn) Receive(msg interface{}) error {
+	conn.reader.reset()
+	err := conn.dec.Decode(msg)
+	if conn.reader.exceeded {
+		return ErrMessageTooLarge
+	}
+	return err
+}
+
+// messageLimitReader returns an error if more than max
+// bytes are read from r since reset was last called.
+type messageLimitReader struct {
+	r        io.Reader
+	max      int64
+	n        int64
+	exceeded bool
+}
+
+func (r *messageLimitReader) reset() {
+	r.n = 0
+	r.exceeded = false
+}
+
+func (r *messageLimitReader) Read(buf []byte) (int, error) {
+	if r.n >= r.max {
+		r.exceeded = true
+		return 0, ErrMessageTooLarge
+	}
+	if remain := r.max - r.n; int64(len(buf)) > remain {
+		buf = buf[:remain]
+	}
+	n, err := r.r.Read(buf)
+	r.n += int64(n)
+	return n, err
+}
+
 type wsJSONConn struct {
 	conn *websocket.Conn
 }
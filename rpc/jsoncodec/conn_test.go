@@ -0,0 +1,65 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package jsoncodec_test
+
+import (
+	"net/http/httptest"
+	"strings"
+
+	"code.google.com/p/go.net/websocket"
+	gc "launchpad.net/gocheck"
+
+	"github.com/juju/juju/rpc"
+	"github.com/juju/juju/rpc/jsoncodec"
+)
+
+type readResult struct {
+	hdr rpc.Header
+	err error
+}
+
+func (*suite) TestWebsocketWithMaxSize(c *gc.C) {
+	const maxSize = 100
+	results := make(chan readResult)
+	srv := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		codec := jsoncodec.NewWebsocketWithMaxSize(conn, maxSize)
+		for {
+			var hdr rpc.Header
+			err := codec.ReadHeader(&hdr)
+			results <- readResult{hdr, err}
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, err := websocket.Dial(url, "", "http://localhost/")
+	c.Assert(err, gc.IsNil)
+	defer conn.Close()
+
+	// Messages up to the limit are received as usual.
+	for i, x := range []string{"small", strings.Repeat("x", 40)} {
+		msg := map[string]interface{}{
+			"RequestId": i + 1,
+			"Type":      "foo",
+			"Params":    map[string]string{"X": x},
+		}
+		err = websocket.JSON.Send(conn, msg)
+		c.Assert(err, gc.IsNil)
+		result := <-results
+		c.Assert(result.err, gc.IsNil)
+		c.Assert(result.hdr.RequestId, gc.Equals, uint64(i+1))
+	}
+
+	// A larger message is rejected.
+	err = websocket.JSON.Send(conn, map[string]interface{}{
+		"RequestId": 3,
+		"Params":    map[string]string{"X": strings.Repeat("x", maxSize)},
+	})
+	c.Assert(err, gc.IsNil)
+	result := <-results
+	c.Assert(result.err, gc.ErrorMatches, "error receiving message: message too large")
+}
@@ -924,6 +924,136 @@ func (*rpcSuite) TestChangeAPIWhileServingRequest(c *gc.C) {
 	}
 }
 
+func (*rpcSuite) TestRequestTimeout(c *gc.C) {
+	ready := make(chan struct{}, 1)
+	done := make(chan string, 1)
+	root := &Root{
+		delayed: map[string]*DelayedMethods{
+			"1": {ready: ready, done: done},
+		},
+	}
+	client, srvDone, _, _ := newRPCClientServer(c, root, nil, false)
+	defer closeClient(c, client, srvDone)
+	// Make a call so that we know the server side of
+	// the connection has been set up.
+	err := client.Call(rpc.Request{"Discard1", "", ""}, nil, nil)
+	c.Assert(err, gc.NotNil)
+	root.conn.SetRequestTimeout(10*time.Millisecond, nil)
+
+	err = client.Call(rpc.Request{"DelayedMethods", "1", "Delay"}, nil, nil)
+	c.Assert(err, gc.ErrorMatches, `request error: request timed out after 10ms \(timeout\)`)
+	c.Assert(err.(rpc.ErrorCoder).ErrorCode(), gc.Equals, rpc.CodeTimeout)
+	chanRead(c, ready, "DelayedMethods.Delay ready")
+	// Let the abandoned call complete.
+	done <- "xxx"
+}
+
+func (*rpcSuite) TestCloseWaitsForTimedOutRequest(c *gc.C) {
+	ready := make(chan struct{}, 1)
+	done := make(chan string, 1)
+	root := &Root{
+		delayed: map[string]*DelayedMethods{
+			"1": {ready: ready, done: done},
+		},
+	}
+	client, srvDone, _, _ := newRPCClientServer(c, root, nil, false)
+	err := client.Call(rpc.Request{"Discard1", "", ""}, nil, nil)
+	c.Assert(err, gc.NotNil)
+	root.conn.SetRequestTimeout(10*time.Millisecond, nil)
+
+	err = client.Call(rpc.Request{"DelayedMethods", "1", "Delay"}, nil, nil)
+	c.Assert(err, gc.ErrorMatches, `request error: request timed out after 10ms \(timeout\)`)
+	chanRead(c, ready, "DelayedMethods.Delay ready")
+
+	// The server connection must not finish closing while the
+	// timed out method is still running.
+	err = client.Close()
+	c.Assert(err, gc.IsNil)
+	select {
+	case <-srvDone:
+		c.Fatalf("server closed with a method still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	done <- "xxx"
+	c.Assert(chanReadError(c, srvDone, "server done"), gc.IsNil)
+}
+
+func (*rpcSuite) TestRequestTimeoutExempt(c *gc.C) {
+	ready := make(chan struct{}, 1)
+	done := make(chan string, 1)
+	root := &Root{
+		delayed: map[string]*DelayedMethods{
+			"1": {ready: ready, done: done},
+		},
+	}
+	client, srvDone, _, _ := newRPCClientServer(c, root, nil, false)
+	defer closeClient(c, client, srvDone)
+	err := client.Call(rpc.Request{"Discard1", "", ""}, nil, nil)
+	c.Assert(err, gc.NotNil)
+	root.conn.SetRequestTimeout(10*time.Millisecond, func(req rpc.Request) bool {
+		return req.Type == "DelayedMethods"
+	})
+
+	result := make(chan error)
+	go func() {
+		var r stringVal
+		err := client.Call(rpc.Request{"DelayedMethods", "1", "Delay"}, nil, &r)
+		c.Check(r.Val, gc.Equals, "xxx")
+		result <- err
+	}()
+	chanRead(c, ready, "DelayedMethods.Delay ready")
+	time.Sleep(50 * time.Millisecond)
+	done <- "xxx"
+	c.Assert(chanReadError(c, result, "Delay result"), gc.IsNil)
+}
+
+type admitterRoot struct {
+	*Root
+	admit func(req rpc.Request) (func(), error)
+}
+
+func (r *admitterRoot) AdmitRequest(req rpc.Request) (func(), error) {
+	return r.admit(req)
+}
+
+func (*rpcSuite) TestRequestAdmitter(c *gc.C) {
+	root := &Root{
+		simple: make(map[string]*SimpleMethods),
+	}
+	root.simple["a99"] = &SimpleMethods{root: root, id: "a99"}
+	var admitted []rpc.Request
+	released := 0
+	srvRoot := &admitterRoot{
+		Root: root,
+		admit: func(req rpc.Request) (func(), error) {
+			admitted = append(admitted, req)
+			if req.Action == "Call0r1" {
+				return nil, fmt.Errorf("not admitted")
+			}
+			return func() { released++ }, nil
+		},
+	}
+	client, srvDone, _, _ := newRPCClientServer(c, srvRoot, nil, false)
+	defer closeClient(c, client, srvDone)
+
+	err := client.Call(rpc.Request{"SimpleMethods", "a99", "Call0r0"}, nil, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(root.calls, gc.HasLen, 1)
+	c.Assert(released, gc.Equals, 1)
+
+	var r stringVal
+	err = client.Call(rpc.Request{"SimpleMethods", "a99", "Call0r1"}, nil, &r)
+	c.Assert(err, gc.ErrorMatches, "request error: not admitted")
+	// The method was not called.
+	c.Assert(root.calls, gc.HasLen, 1)
+	c.Assert(released, gc.Equals, 1)
+
+	c.Assert(admitted, gc.DeepEquals, []rpc.Request{
+		{"SimpleMethods", "a99", "Call0r0"},
+		{"SimpleMethods", "a99", "Call0r1"},
+	})
+}
+
 func chanReadError(c *gc.C, ch <-chan error, what string) error {
 	select {
 	case e := <-ch:
@@ -15,7 +15,10 @@ import (
 	"github.com/juju/juju/rpc/rpcreflect"
 )
 
-const CodeNotImplemented = "not implemented"
+const (
+	CodeNotImplemented = "not implemented"
+	CodeTimeout        = "timeout"
+)
 
 var logger = loggo.GetLogger("juju.rpc")
 
@@ -99,6 +102,11 @@ type Conn struct {
 	// srvPending represents the current server requests.
 	srvPending sync.WaitGroup
 
+	// srvTimed represents the server method calls made under a
+	// request timeout. Calls that time out are no longer counted in
+	// srvPending, but are still counted here until they return.
+	srvTimed sync.WaitGroup
+
 	// sending guards the write side of the codec - it ensures
 	// that codec.WriteMessage is not called concurrently.
 	// It also guards shutdown.
@@ -113,6 +121,14 @@ type Conn struct {
 	// transformErrors is used to transform returned errors.
 	transformErrors func(error) error
 
+	// requestTimeout holds the maximum duration of a server
+	// request, or zero if there is no limit.
+	requestTimeout time.Duration
+
+	// timeoutExempt reports whether a request is exempt
+	// from requestTimeout. It may be nil.
+	timeoutExempt func(Request) bool
+
 	// reqId holds the latest client request id.
 	reqId uint64
 
@@ -249,6 +265,22 @@ func (conn *Conn) Serve(root interface{}, transformErrors func(error) error) {
 	conn.transformErrors = transformErrors
 }
 
+// SetRequestTimeout sets the maximum time that a server request may
+// run for. If a request does not complete within the timeout, an
+// error with code CodeTimeout is returned to the client and the
+// eventual result of the request is discarded; the method itself
+// cannot be interrupted and continues to run in the background.
+// Close still waits for such methods to return.
+// Requests for which exempt returns true are not subject to the
+// timeout; exempt may be nil. A zero timeout removes the limit,
+// which is the default.
+func (conn *Conn) SetRequestTimeout(timeout time.Duration, exempt func(Request) bool) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	conn.requestTimeout = timeout
+	conn.timeoutExempt = exempt
+}
+
 // Dead returns a channel that is closed when the connection
 // has been closed or the underlying transport has received
 // an error. There may still be outstanding requests.
@@ -287,8 +319,10 @@ func (conn *Conn) Close() error {
 	conn.mutex.Unlock()
 
 	// Wait for any outstanding server requests to complete
-	// and write their replies before closing the codec.
+	// and write their replies before closing the codec, then
+	// for any methods still running after their request timed out.
 	conn.srvPending.Wait()
+	conn.srvTimed.Wait()
 
 	// Closing the codec should cause the input loop to terminate.
 	if err := conn.codec.Close(); err != nil {
@@ -311,6 +345,16 @@ type Killer interface {
 	Kill()
 }
 
+// RequestAdmitter represents a type that can decide whether a request
+// may proceed. If the root value passed to Conn.Serve implements
+// RequestAdmitter, AdmitRequest is called before each request on that
+// root is invoked. If it returns an error, the request fails with that
+// error without being invoked; otherwise the returned release function,
+// if non-nil, is called when the invoked method returns.
+type RequestAdmitter interface {
+	AdmitRequest(req Request) (release func(), err error)
+}
+
 // input reads messages from the connection and handles them
 // appropriately.
 func (conn *Conn) input() {
@@ -448,6 +492,9 @@ type boundRequest struct {
 	rpcreflect.MethodCaller
 	transformErrors func(error) error
 	hdr             Header
+	timeout         time.Duration
+	timed           *sync.WaitGroup
+	admitter        RequestAdmitter
 }
 
 // bindRequest searches for methods implementing the
@@ -457,6 +504,10 @@ func (conn *Conn) bindRequest(hdr *Header) (boundRequest, error) {
 	conn.mutex.Lock()
 	rootValue := conn.rootValue
 	transformErrors := conn.transformErrors
+	timeout := conn.requestTimeout
+	if conn.timeoutExempt != nil && conn.timeoutExempt(hdr.Request) {
+		timeout = 0
+	}
 	conn.mutex.Unlock()
 
 	if !rootValue.IsValid() {
@@ -472,17 +523,67 @@ func (conn *Conn) bindRequest(hdr *Header) (boundRequest, error) {
 		}
 		return boundRequest{}, err
 	}
+	admitter, _ := rootValue.GoValue().Interface().(RequestAdmitter)
 	return boundRequest{
 		MethodCaller:    caller,
 		transformErrors: transformErrors,
 		hdr:             *hdr,
+		timeout:         timeout,
+		timed:           &conn.srvTimed,
+		admitter:        admitter,
 	}, nil
 }
 
+// invoke calls the bound method if the request is admitted.
+func (req boundRequest) invoke(arg reflect.Value) (reflect.Value, error) {
+	if req.admitter == nil {
+		return req.Call(req.hdr.Request.Id, arg)
+	}
+	release, err := req.admitter.AdmitRequest(req.hdr.Request)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if release != nil {
+		defer release()
+	}
+	return req.Call(req.hdr.Request.Id, arg)
+}
+
+// call invokes the bound method, giving up with an error
+// if it does not complete within the request's timeout.
+func (req boundRequest) call(arg reflect.Value) (reflect.Value, error) {
+	if req.timeout <= 0 {
+		return req.invoke(arg)
+	}
+	type result struct {
+		rv  reflect.Value
+		err error
+	}
+	// The channel is buffered so that the goroutine
+	// does not leak if we time out.
+	done := make(chan result, 1)
+	req.timed.Add(1)
+	go func() {
+		defer req.timed.Done()
+		rv, err := req.invoke(arg)
+		done <- result{rv, err}
+	}()
+	select {
+	case r := <-done:
+		return r.rv, r.err
+	case <-time.After(req.timeout):
+		logger.Warningf("request %s.%s timed out after %v", req.hdr.Request.Type, req.hdr.Request.Action, req.timeout)
+		return reflect.Value{}, &serverError{
+			Message: fmt.Sprintf("request timed out after %v", req.timeout),
+			Code:    CodeTimeout,
+		}
+	}
+}
+
 // runRequest runs the given request and sends the reply.
 func (conn *Conn) runRequest(req boundRequest, arg reflect.Value, startTime time.Time) {
 	defer conn.srvPending.Done()
-	rv, err := req.Call(req.hdr.Request.Id, arg)
+	rv, err := req.call(arg)
 	if err != nil {
 		err = conn.writeErrorResponse(&req.hdr, req.transformErrors(err), startTime)
 	} else {
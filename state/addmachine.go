@@ -196,6 +196,10 @@ func (st *State) effectiveMachineTemplate(p MachineTemplate, allowStateServer bo
 		return tmpl, fmt.Errorf("cannot specify a nonce without an instance id")
 	}
 
+	if err := validateRequestedNetworks(p.RequestedNetworks); err != nil {
+		return tmpl, err
+	}
+
 	p.Constraints, err = st.resolveConstraints(p.Constraints)
 	if err != nil {
 		return tmpl, err
@@ -574,9 +574,17 @@ type RelationUnitsWatchResults struct {
 
 // CharmsResponse is the server response to charm upload or GET requests.
 type CharmsResponse struct {
-	Error    string   `json:",omitempty"`
-	CharmURL string   `json:",omitempty"`
-	Files    []string `json:",omitempty"`
+	Error     string   `json:",omitempty"`
+	CharmURL  string   `json:",omitempty"`
+	CharmURLs []string `json:",omitempty"`
+	Files     []string `json:",omitempty"`
+}
+
+// HealthResponse is the server response to API server health
+// check requests.
+type HealthResponse struct {
+	Status string
+	Error  string `json:",omitempty"`
 }
 
 // RunParams is used to provide the parameters to the Run method.
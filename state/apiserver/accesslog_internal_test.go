@@ -0,0 +1,118 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// This is an internal package test.
+
+package apiserver
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/juju/loggo"
+	gc "launchpad.net/gocheck"
+
+	"github.com/juju/juju/rpc"
+	"github.com/juju/juju/testing"
+)
+
+type accessLogSuite struct {
+	testing.BaseSuite
+	writer *loggo.TestWriter
+}
+
+var _ = gc.Suite(&accessLogSuite{})
+
+func (s *accessLogSuite) SetUpTest(c *gc.C) {
+	s.BaseSuite.SetUpTest(c)
+	s.writer = &loggo.TestWriter{}
+	c.Assert(loggo.RegisterWriter("access-log-tester", s.writer, loggo.TRACE), gc.IsNil)
+	s.AddCleanup(func(*gc.C) {
+		loggo.RemoveWriter("access-log-tester")
+	})
+}
+
+func (s *accessLogSuite) accessLogMessages() []string {
+	var messages []string
+	for _, m := range s.writer.Log {
+		if m.Module == "juju.state.apiserver.access" {
+			messages = append(messages, m.Message)
+		}
+	}
+	return messages
+}
+
+func (s *accessLogSuite) TestStructuredAccessLog(c *gc.C) {
+	n := newRequestNotifier(&Server{structuredAccessLog: true})
+	n.login("machine-0")
+	req := rpc.Request{Type: "Machiner", Action: "Life"}
+	hdr := &rpc.Header{Error: "boom"}
+	n.ServerReply(req, hdr, struct{ A string }{"x"}, 1500*time.Millisecond)
+
+	messages := s.accessLogMessages()
+	c.Assert(messages, gc.HasLen, 1)
+	var entry map[string]interface{}
+	err := json.Unmarshal([]byte(messages[0]), &entry)
+	c.Assert(err, gc.IsNil)
+	delete(entry, "id")
+	c.Assert(entry, gc.DeepEquals, map[string]interface{}{
+		"tag":           "machine-0",
+		"type":          "Machiner",
+		"action":        "Life",
+		"duration":      1.5,
+		"response-size": float64(len(`{"A":"x"}`)),
+		"error":         "boom",
+	})
+}
+
+func (s *accessLogSuite) TestStructuredAccessLogDisabled(c *gc.C) {
+	n := newRequestNotifier(&Server{})
+	req := rpc.Request{Type: "Machiner", Action: "Life"}
+	n.ServerReply(req, &rpc.Header{}, struct{}{}, time.Second)
+	c.Assert(s.accessLogMessages(), gc.HasLen, 0)
+}
+
+func (s *accessLogSuite) TestStructuredAccessLogIgnoresPings(c *gc.C) {
+	n := newRequestNotifier(&Server{structuredAccessLog: true})
+	req := rpc.Request{Type: "Pinger", Action: "Ping"}
+	n.ServerReply(req, &rpc.Header{}, struct{}{}, time.Second)
+	c.Assert(s.accessLogMessages(), gc.HasLen, 0)
+}
+
+func (s *accessLogSuite) TestSlowRequests(c *gc.C) {
+	srv := &Server{slowRequestThreshold: time.Second}
+	n := newRequestNotifier(srv)
+	n.login("machine-0")
+	req := rpc.Request{Type: "Machiner", Id: "0", Action: "Life"}
+	n.ServerReply(req, &rpc.Header{}, struct{}{}, 500*time.Millisecond)
+	n.ServerReply(req, &rpc.Header{}, struct{}{}, 2*time.Second)
+
+	c.Assert(srv.Stats().SlowRequests, gc.Equals, int64(1))
+	var warnings []string
+	for _, m := range s.writer.Log {
+		if m.Level == loggo.WARNING {
+			warnings = append(warnings, m.Message)
+		}
+	}
+	c.Assert(warnings, gc.HasLen, 1)
+	c.Assert(warnings[0], gc.Matches, `\[[0-9A-F]+\] machine-0 slow request Machiner\["0"\]\.Life took 2s`)
+}
+
+func (s *accessLogSuite) TestSlowRequestsIgnorePings(c *gc.C) {
+	srv := &Server{slowRequestThreshold: time.Second}
+	n := newRequestNotifier(srv)
+	req := rpc.Request{Type: "Pinger", Action: "Ping"}
+	n.ServerReply(req, &rpc.Header{}, struct{}{}, 2*time.Second)
+	c.Assert(srv.Stats().SlowRequests, gc.Equals, int64(0))
+}
+
+func (s *accessLogSuite) TestSlowRequestsIgnoreWatchers(c *gc.C) {
+	srv := &Server{slowRequestThreshold: time.Second}
+	n := newRequestNotifier(srv)
+	req := rpc.Request{Type: "NotifyWatcher", Id: "1", Action: "Next"}
+	n.ServerReply(req, &rpc.Header{}, struct{}{}, 2*time.Second)
+	c.Assert(srv.Stats().SlowRequests, gc.Equals, int64(0))
+	for _, m := range s.writer.Log {
+		c.Check(m.Level, gc.Not(gc.Equals), loggo.WARNING)
+	}
+}
@@ -6,6 +6,7 @@ package apiserver
 import (
 	stderrors "errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/juju/errors"
 	"github.com/juju/names"
@@ -82,6 +83,8 @@ func (a *srvAdmin) Login(c params.Creds) (params.LoginResult, error) {
 			return params.LoginResult{}, common.ErrTryAgain
 		}
 		defer a.limiter.Release()
+		atomic.AddInt64(&a.root.srv.activeLogins, 1)
+		defer atomic.AddInt64(&a.root.srv.activeLogins, -1)
 	}
 	entity, err := doCheckCreds(a.root.srv.state, c)
 	if err != nil {
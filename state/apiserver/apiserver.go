@@ -5,9 +5,11 @@ package apiserver
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -24,53 +26,245 @@ import (
 	"github.com/juju/juju/state/apiserver/common"
 )
 
-var logger = loggo.GetLogger("juju.state.apiserver")
+var (
+	logger = loggo.GetLogger("juju.state.apiserver")
 
-// loginRateLimit defines how many concurrent Login requests we will
-// accept
-const loginRateLimit = 10
+	// accessLogger is used to emit structured access
+	// log entries when they are enabled.
+	accessLogger = loggo.GetLogger("juju.state.apiserver.access")
+)
+
+// DefaultLoginRateLimit defines how many concurrent Login requests we
+// will accept if no other limit is specified.
+const DefaultLoginRateLimit = 10
+
+// DefaultMaxRequestSize defines the maximum size in bytes of an RPC
+// request message if no other limit is specified. Charms and tools
+// are uploaded over HTTP rather than RPC, so are not affected by it.
+const DefaultMaxRequestSize = 32 * 1024 * 1024
 
 // Server holds the server side of the API.
 type Server struct {
+	// The following counters are accessed atomically and
+	// are kept first to ensure 64-bit alignment.
+	activeConnections int64
+	totalRequests     int64
+	activeLogins      int64
+	mongoPingFailures int64
+	slowRequests      int64
+
 	tomb        tomb.Tomb
 	wg          sync.WaitGroup
 	state       *state.State
 	environUUID string
-	addr        net.Addr
+	listeners   []net.Listener
 	dataDir     string
 	logDir      string
 	limiter     utils.Limiter
+
+	mongoPingInterval         time.Duration
+	mongoPingFailureThreshold int
+	maxRequestDuration        time.Duration
+	structuredAccessLog       bool
+	maxRequestSize            int64
+	slowRequestThreshold      time.Duration
+	entityLimiter             *entityLimiter
+	readOnly                  bool
+}
+
+// ServerConfig holds parameters required to set up an API server.
+type ServerConfig struct {
+	// Addrs holds the addresses the server listens on.
+	// All of them are served identically.
+	Addrs []string
+
+	// Cert and Key hold the server's TLS certificate and
+	// private key, in PEM format.
+	Cert []byte
+	Key  []byte
+
+	// TLSMinVersion holds the minimum TLS version accepted by the
+	// server, for example tls.VersionTLS12. If it is zero, the Go
+	// default is used.
+	TLSMinVersion uint16
+
+	// TLSCipherSuites holds the TLS cipher suites the server may
+	// negotiate. If it is empty, the Go default is used.
+	TLSCipherSuites []uint16
+
+	// DataDir and LogDir are the agent's data and log directories.
+	DataDir string
+	LogDir  string
+
+	// LoginRateLimit holds the maximum number of agent Login
+	// requests that will be processed concurrently. If it is not
+	// positive, DefaultLoginRateLimit is used.
+	LoginRateLimit int
+
+	// MongoPingInterval holds the interval at which the server pings
+	// mongo to check that it is still alive. If it is zero, a default
+	// of 10 seconds is used.
+	MongoPingInterval time.Duration
+
+	// MongoPingFailureThreshold holds the number of consecutive
+	// failed mongo pings after which the server is terminated.
+	// If it is zero, a default of 3 is used.
+	MongoPingFailureThreshold int
+
+	// MaxRequestDuration holds the maximum time an RPC request
+	// may take before it is abandoned and an error returned to
+	// the client. Pinger.Ping and watcher requests, which are
+	// expected to block, are exempt. If it is zero, requests are
+	// not limited.
+	MaxRequestDuration time.Duration
+
+	// StructuredAccessLog specifies that a JSON object describing
+	// each RPC request should be logged at INFO level to the
+	// "juju.state.apiserver.access" logger, in addition to the
+	// usual debug logging.
+	StructuredAccessLog bool
+
+	// MaxRequestSize holds the maximum size in bytes of an RPC
+	// request message. A connection that sends a larger message is
+	// closed. If it is zero, DefaultMaxRequestSize is used.
+	MaxRequestSize int64
+
+	// SlowRequestThreshold holds the duration above which an RPC
+	// request is considered slow. Slow requests are logged as
+	// warnings and counted in ServerStats. If it is zero, requests
+	// are not checked.
+	SlowRequestThreshold time.Duration
+
+	// MaxRequestsPerEntity holds the maximum number of concurrent
+	// RPC requests that may be made on behalf of any single
+	// authenticated entity, across all of its connections. Requests
+	// beyond the limit fail with a "try again" error. Pings and
+	// watcher requests are not counted. If it is zero, requests are
+	// not limited.
+	MaxRequestsPerEntity int
+
+	// ReadOnly specifies whether the server rejects RPC requests
+	// that are known to change state. Such requests fail with a
+	// "read only" error; logins, watchers and other requests are
+	// served as usual.
+	ReadOnly bool
 }
 
 // NewServer serves the given state by accepting requests on the given
 // listener, using the given certificate and key (in PEM format) for
-// authentication.
-func NewServer(s *state.State, addr string, cert, key []byte, datadir, logDir string) (*Server, error) {
-	lis, err := net.Listen("tcp", addr)
-	if err != nil {
-		return nil, err
+// authentication. At most loginRateLimit agent Login requests will be
+// processed concurrently; if it is not positive, DefaultLoginRateLimit
+// is used.
+//
+// NewServer is a convenience wrapper around NewServerWithConfig.
+func NewServer(s *state.State, addr string, cert, key []byte, datadir, logDir string, loginRateLimit int) (*Server, error) {
+	return NewServerWithConfig(s, ServerConfig{
+		Addrs:          []string{addr},
+		Cert:           cert,
+		Key:            key,
+		DataDir:        datadir,
+		LogDir:         logDir,
+		LoginRateLimit: loginRateLimit,
+	})
+}
+
+// NewServerWithConfig serves the given state by accepting requests
+// as described by the given configuration.
+func NewServerWithConfig(s *state.State, cfg ServerConfig) (*Server, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, fmt.Errorf("no addresses to listen on")
 	}
-	logger.Infof("listening on %q", lis.Addr())
-	tlsCert, err := tls.X509KeyPair(cert, key)
+	tlsCert, err := tls.X509KeyPair(cfg.Cert, cfg.Key)
 	if err != nil {
 		return nil, err
 	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{tlsCert},
+		MinVersion:   cfg.TLSMinVersion,
+		CipherSuites: cfg.TLSCipherSuites,
+	}
+	loginRateLimit := cfg.LoginRateLimit
+	if loginRateLimit <= 0 {
+		loginRateLimit = DefaultLoginRateLimit
+	}
 	srv := &Server{
 		state:   s,
-		addr:    lis.Addr(),
-		dataDir: datadir,
-		logDir:  logDir,
+		dataDir: cfg.DataDir,
+		logDir:  cfg.LogDir,
 		limiter: utils.NewLimiter(loginRateLimit),
+
+		mongoPingInterval:         cfg.MongoPingInterval,
+		mongoPingFailureThreshold: cfg.MongoPingFailureThreshold,
+		maxRequestDuration:        cfg.MaxRequestDuration,
+		structuredAccessLog:       cfg.StructuredAccessLog,
+		maxRequestSize:            cfg.MaxRequestSize,
+		slowRequestThreshold:      cfg.SlowRequestThreshold,
+		readOnly:                  cfg.ReadOnly,
+	}
+	if srv.mongoPingInterval <= 0 {
+		srv.mongoPingInterval = mongoPingInterval
+	}
+	if srv.mongoPingFailureThreshold <= 0 {
+		srv.mongoPingFailureThreshold = mongoPingFailureThreshold
+	}
+	if srv.maxRequestSize <= 0 {
+		srv.maxRequestSize = DefaultMaxRequestSize
+	}
+	if cfg.MaxRequestsPerEntity > 0 {
+		srv.entityLimiter = newEntityLimiter(cfg.MaxRequestsPerEntity)
+	}
+	for _, addr := range cfg.Addrs {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			srv.closeListeners()
+			return nil, err
+		}
+		logger.Infof("listening on %q", lis.Addr())
+		srv.listeners = append(srv.listeners, tls.NewListener(lis, tlsConfig))
 	}
 	// TODO(rog) check that *srvRoot is a valid type for using
 	// as an RPC server.
-	lis = tls.NewListener(lis, &tls.Config{
-		Certificates: []tls.Certificate{tlsCert},
-	})
-	go srv.run(lis)
+	go srv.run()
 	return srv, nil
 }
 
+// closeListeners closes all the server's listeners.
+func (srv *Server) closeListeners() {
+	for _, lis := range srv.listeners {
+		// The error from closing the listener is not interesting;
+		// it may already have been closed.
+		lis.Close()
+	}
+}
+
+// ServerStats holds statistics about a running API server.
+type ServerStats struct {
+	// ActiveConnections holds the number of open API connections.
+	ActiveConnections int64
+
+	// TotalRequests holds the number of RPC requests served
+	// since the server was started.
+	TotalRequests int64
+
+	// ActiveLogins holds the number of agent Login requests
+	// currently occupying a slot in the login rate limiter.
+	ActiveLogins int64
+
+	// SlowRequests holds the number of RPC requests that took
+	// longer than the configured slow request threshold.
+	SlowRequests int64
+}
+
+// Stats returns a snapshot of the server's statistics.
+func (srv *Server) Stats() ServerStats {
+	return ServerStats{
+		ActiveConnections: atomic.LoadInt64(&srv.activeConnections),
+		TotalRequests:     atomic.LoadInt64(&srv.totalRequests),
+		ActiveLogins:      atomic.LoadInt64(&srv.activeLogins),
+		SlowRequests:      atomic.LoadInt64(&srv.slowRequests),
+	}
+}
+
 // Dead returns a channel that signals when the server has exited.
 func (srv *Server) Dead() <-chan struct{} {
 	return srv.tomb.Dead()
@@ -83,6 +277,32 @@ func (srv *Server) Stop() error {
 	return srv.tomb.Wait()
 }
 
+// drainPollInterval defines how often Drain checks whether
+// all connections have terminated.
+const drainPollInterval = 100 * time.Millisecond
+
+// Drain stops the server accepting new connections and waits up to
+// the given timeout for existing connections to terminate. It then
+// stops the server, forcibly closing any connections that remain.
+func (srv *Server) Drain(timeout time.Duration) error {
+	srv.closeListeners()
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&srv.activeConnections) > 0 {
+		select {
+		case <-ticker.C:
+		case <-srv.tomb.Dying():
+			return srv.Stop()
+		case <-deadline:
+			logger.Warningf("timed out draining API server; closing %d remaining connection(s)",
+				atomic.LoadInt64(&srv.activeConnections))
+			return srv.Stop()
+		}
+	}
+	return srv.Stop()
+}
+
 // Kill implements worker.Worker.Kill.
 func (srv *Server) Kill() {
 	srv.tomb.Kill(nil)
@@ -96,6 +316,7 @@ func (srv *Server) Wait() error {
 type requestNotifier struct {
 	id    int64
 	start time.Time
+	srv   *Server
 
 	mu   sync.Mutex
 	tag_ string
@@ -103,11 +324,12 @@ type requestNotifier struct {
 
 var globalCounter int64
 
-func newRequestNotifier() *requestNotifier {
+func newRequestNotifier(srv *Server) *requestNotifier {
 	return &requestNotifier{
 		id:    atomic.AddInt64(&globalCounter, 1),
 		tag_:  "<unknown>",
 		start: time.Now(),
+		srv:   srv,
 	}
 }
 
@@ -124,8 +346,27 @@ func (n *requestNotifier) tag() (tag string) {
 	return
 }
 
+// debugEnabled reports whether requests should be logged.
+func (n *requestNotifier) debugEnabled() bool {
+	return logger.EffectiveLogLevel() <= loggo.DEBUG
+}
+
+// isPing reports whether the request is a Pinger heartbeat.
+func isPing(req rpc.Request) bool {
+	return req.Type == "Pinger" && req.Action == "Ping"
+}
+
+// isLongLived reports whether the request is expected to block for
+// an extended period, such as a ping or a watcher's Next call.
+func isLongLived(req rpc.Request) bool {
+	return isPing(req) || strings.HasSuffix(req.Type, "Watcher")
+}
+
 func (n *requestNotifier) ServerRequest(hdr *rpc.Header, body interface{}) {
-	if hdr.Request.Type == "Pinger" && hdr.Request.Action == "Ping" {
+	if isPing(hdr.Request) {
+		return
+	}
+	if !n.debugEnabled() {
 		return
 	}
 	// TODO(rog) 2013-10-11 remove secrets from some requests.
@@ -133,12 +374,59 @@ func (n *requestNotifier) ServerRequest(hdr *rpc.Header, body interface{}) {
 }
 
 func (n *requestNotifier) ServerReply(req rpc.Request, hdr *rpc.Header, body interface{}, timeSpent time.Duration) {
-	if req.Type == "Pinger" && req.Action == "Ping" {
+	atomic.AddInt64(&n.srv.totalRequests, 1)
+	if isPing(req) {
+		return
+	}
+	// Watcher requests block until there is a change, so they
+	// are expected to exceed any threshold.
+	if threshold := n.srv.slowRequestThreshold; threshold > 0 && timeSpent > threshold && !isLongLived(req) {
+		atomic.AddInt64(&n.srv.slowRequests, 1)
+		logger.Warningf("[%X] %s slow request %s[%q].%s took %v", n.id, n.tag(), req.Type, req.Id, req.Action, timeSpent)
+	}
+	if n.srv.structuredAccessLog {
+		n.logAccess(req, hdr, body, timeSpent)
+	}
+	if !n.debugEnabled() {
 		return
 	}
 	logger.Debugf("-> [%X] %s %s %s %s[%q].%s", n.id, n.tag(), timeSpent, jsoncodec.DumpRequest(hdr, body), req.Type, req.Id, req.Action)
 }
 
+// accessLogEntry holds the fields of a structured access log entry.
+type accessLogEntry struct {
+	Id           string  `json:"id"`
+	Tag          string  `json:"tag"`
+	Type         string  `json:"type"`
+	Action       string  `json:"action"`
+	Duration     float64 `json:"duration"`
+	ResponseSize int     `json:"response-size"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// logAccess logs a JSON object describing the given reply.
+// The duration is recorded in seconds and the response size
+// is that of the JSON encoded reply body.
+func (n *requestNotifier) logAccess(req rpc.Request, hdr *rpc.Header, body interface{}, timeSpent time.Duration) {
+	entry := accessLogEntry{
+		Id:       fmt.Sprintf("%X", n.id),
+		Tag:      n.tag(),
+		Type:     req.Type,
+		Action:   req.Action,
+		Duration: timeSpent.Seconds(),
+		Error:    hdr.Error,
+	}
+	if data, err := json.Marshal(body); err == nil {
+		entry.ResponseSize = len(data)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logger.Errorf("cannot marshal access log entry: %v", err)
+		return
+	}
+	accessLogger.Infof("%s", data)
+}
+
 func (n *requestNotifier) join(req *http.Request) {
 	logger.Infof("[%X] API connection from %s", n.id, req.RemoteAddr)
 }
@@ -162,13 +450,13 @@ func handleAll(mux *pat.PatternServeMux, pattern string, handler http.Handler) {
 	mux.Options(pattern, handler)
 }
 
-func (srv *Server) run(lis net.Listener) {
+func (srv *Server) run() {
 	defer srv.tomb.Done()
 	defer srv.wg.Wait() // wait for any outstanding requests to complete.
 	srv.wg.Add(1)
 	go func() {
 		<-srv.tomb.Dying()
-		lis.Close()
+		srv.closeListeners()
 		srv.wg.Done()
 	}()
 	srv.wg.Add(1)
@@ -200,6 +488,9 @@ func (srv *Server) run(lis net.Listener) {
 		&toolsHandler{httpHandler{state: srv.state}},
 	)
 	handleAll(mux, "/environment/:envuuid/api", http.HandlerFunc(srv.apiHandler))
+	// The health check is unauthenticated so that load
+	// balancers can use it.
+	mux.Get("/health", &healthHandler{srv})
 	// For backwards compatibility we register all the old paths
 	handleAll(mux, "/log",
 		&debugLogHandler{
@@ -215,12 +506,18 @@ func (srv *Server) run(lis net.Listener) {
 		&toolsHandler{httpHandler{state: srv.state}},
 	)
 	handleAll(mux, "/", http.HandlerFunc(srv.apiHandler))
-	// The error from http.Serve is not interesting.
-	http.Serve(lis, mux)
+	for _, lis := range srv.listeners {
+		srv.wg.Add(1)
+		go func(lis net.Listener) {
+			defer srv.wg.Done()
+			// The error from http.Serve is not interesting.
+			http.Serve(lis, mux)
+		}(lis)
+	}
 }
 
 func (srv *Server) apiHandler(w http.ResponseWriter, req *http.Request) {
-	reqNotifier := newRequestNotifier()
+	reqNotifier := newRequestNotifier(srv)
 	reqNotifier.join(req)
 	defer reqNotifier.leave()
 	wsServer := websocket.Server{
@@ -234,19 +531,30 @@ func (srv *Server) apiHandler(w http.ResponseWriter, req *http.Request) {
 			if srv.tomb.Err() != tomb.ErrStillAlive {
 				return
 			}
+			atomic.AddInt64(&srv.activeConnections, 1)
+			defer atomic.AddInt64(&srv.activeConnections, -1)
 			envUUID := req.URL.Query().Get(":envuuid")
 			logger.Tracef("got a request for env %q", envUUID)
 			if err := srv.serveConn(conn, reqNotifier, envUUID); err != nil {
-				logger.Errorf("error serving RPCs: %v", err)
+				logger.Errorf("[%X] %s error serving RPCs: %v", reqNotifier.id, reqNotifier.tag(), err)
 			}
 		},
 	}
 	wsServer.ServeHTTP(w, req)
 }
 
-// Addr returns the address that the server is listening on.
+// Addr returns the first address that the server is listening on.
 func (srv *Server) Addr() string {
-	return srv.addr.String()
+	return srv.listeners[0].Addr().String()
+}
+
+// Addrs returns all the addresses that the server is listening on.
+func (srv *Server) Addrs() []string {
+	addrs := make([]string, len(srv.listeners))
+	for i, lis := range srv.listeners {
+		addrs[i] = lis.Addr().String()
+	}
+	return addrs
 }
 
 func (srv *Server) validateEnvironUUID(envUUID string) error {
@@ -274,17 +582,14 @@ func (srv *Server) validateEnvironUUID(envUUID string) error {
 }
 
 func (srv *Server) serveConn(wsConn *websocket.Conn, reqNotifier *requestNotifier, envUUID string) error {
-	codec := jsoncodec.NewWebsocket(wsConn)
+	codec := jsoncodec.NewWebsocketWithMaxSize(wsConn, srv.maxRequestSize)
 	if loggo.GetLogger("juju.rpc.jsoncodec").EffectiveLogLevel() <= loggo.TRACE {
 		codec.SetLogging(true)
 	}
-	var notifier rpc.RequestNotifier
-	if logger.EffectiveLogLevel() <= loggo.DEBUG {
-		// Incur request monitoring overhead only if we
-		// know we'll need it.
-		notifier = reqNotifier
-	}
-	conn := rpc.NewConn(codec, notifier)
+	// The notifier is always installed so that requests are counted;
+	// it only incurs logging overhead when debug logging is enabled.
+	conn := rpc.NewConn(codec, reqNotifier)
+	conn.SetRequestTimeout(srv.maxRequestDuration, isLongLived)
 	err := srv.validateEnvironUUID(envUUID)
 	if err != nil {
 		conn.Serve(&errRoot{err}, serverError)
@@ -300,19 +605,46 @@ func (srv *Server) serveConn(wsConn *websocket.Conn, reqNotifier *requestNotifie
 }
 
 func (srv *Server) mongoPinger() error {
+	return pingMongo(
+		srv.state.MongoSession(),
+		srv.mongoPingInterval,
+		srv.mongoPingFailureThreshold,
+		srv.tomb.Dying(),
+		&srv.mongoPingFailures,
+	)
+}
+
+// mongoSession holds the methods of *mgo.Session used by pingMongo.
+type mongoSession interface {
+	Ping() error
+	Refresh()
+}
+
+// pingMongo pings the given session at the given interval until
+// the dying channel is closed or threshold consecutive pings have
+// failed. A successful ping resets the failure count. The current
+// number of consecutive failures is stored atomically in *failures.
+func pingMongo(session mongoSession, interval time.Duration, threshold int, dying <-chan struct{}, failures *int64) error {
 	timer := time.NewTimer(0)
-	session := srv.state.MongoSession()
 	for {
 		select {
 		case <-timer.C:
-		case <-srv.tomb.Dying():
+		case <-dying:
 			return tomb.ErrDying
 		}
 		if err := session.Ping(); err != nil {
-			logger.Infof("got error pinging mongo: %v", err)
-			return fmt.Errorf("error pinging mongo: %v", err)
+			n := atomic.AddInt64(failures, 1)
+			logger.Warningf("got error pinging mongo (%d/%d): %v", n, threshold, err)
+			if n >= int64(threshold) {
+				return fmt.Errorf("error pinging mongo: %v", err)
+			}
+			// Discard the failed socket so that the
+			// next ping has a chance to succeed.
+			session.Refresh()
+		} else {
+			atomic.StoreInt64(failures, 0)
 		}
-		timer.Reset(mongoPingInterval)
+		timer.Reset(interval)
 	}
 }
 
@@ -40,12 +40,7 @@ type charmsHandler struct {
 type bundleContentSenderFunc func(w http.ResponseWriter, r *http.Request, bundle *charm.Bundle)
 
 func (h *charmsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if err := h.authenticate(r); err != nil {
-		h.authError(w, h)
-		return
-	}
-	if err := h.validateEnvironUUID(r); err != nil {
-		h.sendError(w, http.StatusNotFound, err.Error())
+	if !h.checkRequest(w, r, h) {
 		return
 	}
 
@@ -62,7 +57,18 @@ func (h *charmsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		// Retrieve or list charm files.
 		// Requires "url" (charm URL) and an optional "file" (the path to the
-		// charm file) to be included in the query.
+		// charm file) to be included in the query. With neither, the list of
+		// uploaded local charm URLs is returned.
+		query := r.URL.Query()
+		if query.Get("url") == "" && query.Get("file") == "" {
+			charmURLs, err := h.localCharmURLs()
+			if err != nil {
+				h.sendError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			h.sendJSON(w, http.StatusOK, &params.CharmsResponse{CharmURLs: charmURLs})
+			return
+		}
 		if charmArchivePath, filePath, err := h.processGet(r); err != nil {
 			// An error occurred retrieving the charm bundle.
 			h.sendError(w, http.StatusBadRequest, err.Error())
@@ -78,6 +84,21 @@ func (h *charmsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// localCharmURLs returns the sorted URLs of the local charms uploaded
+// to the environment.
+func (h *charmsHandler) localCharmURLs() ([]string, error) {
+	curls, err := h.state.LocalCharmURLs()
+	if err != nil {
+		return nil, err
+	}
+	charmURLs := make([]string, len(curls))
+	for i, curl := range curls {
+		charmURLs[i] = curl.String()
+	}
+	sort.Strings(charmURLs)
+	return charmURLs, nil
+}
+
 // sendJSON sends a JSON-encoded response to the client.
 func (h *charmsHandler) sendJSON(w http.ResponseWriter, statusCode int, response *params.CharmsResponse) error {
 	w.Header().Set("Content-Type", "application/json")
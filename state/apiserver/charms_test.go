@@ -132,7 +132,7 @@ func (s *charmsSuite) TestAuthRequiresUser(c *gc.C) {
 	// Now try a user login.
 	resp, err = s.authRequest(c, "GET", s.charmsURI(c, ""), "", nil)
 	c.Assert(err, gc.IsNil)
-	s.assertErrorResponse(c, resp, http.StatusBadRequest, "expected url=CharmURL query argument")
+	s.assertGetCharmListResponse(c, resp, nil)
 }
 
 func (s *charmsSuite) TestUploadRequiresSeries(c *gc.C) {
@@ -264,7 +264,7 @@ func (s *charmsSuite) TestUploadRejectsWrongEnvUUIDPath(c *gc.C) {
 	url.Path = "/environment/dead-beef-123456/charms"
 	resp, err := s.authRequest(c, "POST", url.String(), "", nil)
 	c.Assert(err, gc.IsNil)
-	s.assertErrorResponse(c, resp, http.StatusNotFound, `unknown environment: "dead-beef-123456"`)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest, `unknown environment: "dead-beef-123456"`)
 }
 
 func (s *charmsSuite) TestUploadRepackagesNestedArchives(c *gc.C) {
@@ -334,6 +334,27 @@ func (s *charmsSuite) TestGetRequiresCharmURL(c *gc.C) {
 	)
 }
 
+func (s *charmsSuite) TestGetListsLocalCharms(c *gc.C) {
+	resp, err := s.authRequest(c, "GET", s.charmsURI(c, ""), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertGetCharmListResponse(c, resp, nil)
+
+	ch := charmtesting.Charms.Bundle(c.MkDir(), "dummy")
+	resp, err = s.uploadRequest(c, s.charmsURI(c, "?series=quantal"), true, ch.Path)
+	c.Assert(err, gc.IsNil)
+	s.assertUploadResponse(c, resp, "local:quantal/dummy-1")
+	resp, err = s.uploadRequest(c, s.charmsURI(c, "?series=precise"), true, ch.Path)
+	c.Assert(err, gc.IsNil)
+	s.assertUploadResponse(c, resp, "local:precise/dummy-1")
+
+	resp, err = s.authRequest(c, "GET", s.charmsURI(c, ""), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertGetCharmListResponse(c, resp, []string{
+		"local:precise/dummy-1",
+		"local:quantal/dummy-1",
+	})
+}
+
 func (s *charmsSuite) TestGetFailsWithInvalidCharmURL(c *gc.C) {
 	uri := s.charmsURI(c, "?url=local:precise/no-such")
 	resp, err := s.authRequest(c, "GET", uri, "", nil)
@@ -446,7 +467,7 @@ func (s *charmsSuite) TestGetRejectsWrongEnvUUIDPath(c *gc.C) {
 	url.Path = "/environment/dead-beef-123456/charms"
 	resp, err := s.authRequest(c, "GET", url.String(), "", nil)
 	c.Assert(err, gc.IsNil)
-	s.assertErrorResponse(c, resp, http.StatusNotFound, `unknown environment: "dead-beef-123456"`)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest, `unknown environment: "dead-beef-123456"`)
 }
 
 func (s *charmsSuite) TestGetReturnsManifest(c *gc.C) {
@@ -528,6 +549,13 @@ func (s *charmsSuite) assertGetFileListResponse(c *gc.C, resp *http.Response, ex
 	c.Check(charmResponse.Files, gc.DeepEquals, expFiles)
 }
 
+func (s *charmsSuite) assertGetCharmListResponse(c *gc.C, resp *http.Response, expCharmURLs []string) {
+	body := assertResponse(c, resp, http.StatusOK, "application/json")
+	charmResponse := jsonResponse(c, body)
+	c.Check(charmResponse.Error, gc.Equals, "")
+	c.Check(charmResponse.CharmURLs, gc.DeepEquals, expCharmURLs)
+}
+
 func (s *charmsSuite) assertErrorResponse(c *gc.C, resp *http.Response, expCode int, expError string) {
 	body := assertResponse(c, resp, expCode, "application/json")
 	c.Check(jsonResponse(c, body).Error, gc.Matches, expError)
@@ -71,6 +71,7 @@ var (
 	ErrStoppedWatcher = stderrors.New("watcher has been stopped")
 	ErrBadRequest     = stderrors.New("invalid request")
 	ErrTryAgain       = stderrors.New("try again")
+	ErrReadOnly       = stderrors.New("api server is read only")
 )
 
 var singletonErrorCodes = map[error]string{
@@ -85,6 +86,7 @@ var singletonErrorCodes = map[error]string{
 	ErrUnknownWatcher:            params.CodeNotFound,
 	ErrStoppedWatcher:            params.CodeStopped,
 	ErrTryAgain:                  params.CodeTryAgain,
+	ErrReadOnly:                  params.CodeReadOnly,
 }
 
 func singletonCode(err error) (string, bool) {
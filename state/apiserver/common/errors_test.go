@@ -98,6 +98,10 @@ var errorTransformTests = []struct {
 	err:        common.ErrTryAgain,
 	code:       params.CodeTryAgain,
 	helperFunc: params.IsCodeTryAgain,
+}, {
+	err:        common.ErrReadOnly,
+	code:       params.CodeReadOnly,
+	helperFunc: params.IsCodeReadOnly,
 }, {
 	err:  stderrors.New("an error"),
 	code: "",
@@ -4,6 +4,7 @@
 package apiserver
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -46,6 +47,9 @@ var maxLinesReached = fmt.Errorf("max lines reached")
 //      - has no meaning if 'replay' is true
 //   level -> string one of [TRACE, DEBUG, INFO, WARNING, ERROR]
 //   replay -> string - one of [true, false], if true, start the file from the start
+//
+// If the request has an Accept-Encoding header allowing gzip, the log
+// lines following the initial JSON error line are gzip compressed.
 func (h *debugLogHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	server := websocket.Server{
 		Handler: func(socket *websocket.Conn) {
@@ -90,10 +94,20 @@ func (h *debugLogHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 				return
 			}
 
-			stream.start(logFile, socket)
+			var out io.Writer = socket
+			var gz *gzipLineWriter
+			if acceptsGzip(req) {
+				gz = &gzipLineWriter{gzip.NewWriter(socket)}
+				out = gz
+			}
+			stream.start(logFile, out)
 			go func() {
 				defer stream.tomb.Done()
 				defer socket.Close()
+				if gz != nil {
+					// Write the gzip trailer before the socket is closed.
+					defer gz.Close()
+				}
 				stream.tomb.Kill(stream.loop())
 			}()
 			if err := stream.tomb.Wait(); err != nil {
@@ -155,6 +169,46 @@ func newLogStream(queryMap url.Values) (*logStream, error) {
 	}, nil
 }
 
+// acceptsGzip reports whether the request allows a gzip encoded response.
+// A gzip coding with a quality value of zero is explicitly refused.
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(enc, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[len("q="):], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipLineWriter compresses everything written to it, flushing after
+// each write so that log lines are still streamed as they arrive.
+type gzipLineWriter struct {
+	gz *gzip.Writer
+}
+
+func (w *gzipLineWriter) Write(buf []byte) (int, error) {
+	n, err := w.gz.Write(buf)
+	if err != nil {
+		return n, err
+	}
+	return n, w.gz.Flush()
+}
+
+// Close flushes any remaining data and writes the gzip trailer. It
+// does not close the underlying writer.
+func (w *gzipLineWriter) Close() error {
+	return w.gz.Close()
+}
+
 // sendError sends a JSON-encoded error response.
 func (h *debugLogHandler) sendError(w io.Writer, err error) error {
 	response := &params.ErrorResult{}
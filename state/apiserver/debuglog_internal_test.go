@@ -7,6 +7,7 @@ package apiserver
 
 import (
 	"bytes"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -296,6 +297,29 @@ line 5
 	s.testStreamInternal(c, false, 0, 3, expected, "")
 }
 
+func (s *debugInternalSuite) TestAcceptsGzip(c *gc.C) {
+	for i, test := range []struct {
+		header string
+		expect bool
+	}{
+		{"", false},
+		{"deflate", false},
+		{"gzip", true},
+		{"deflate, gzip", true},
+		{"gzip;q=0.5", true},
+		{"gzip; q=1.0", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0.000", false},
+		{"gzip;q=bad", false},
+		{"gzipped", false},
+	} {
+		c.Logf("test %d: %q", i, test.header)
+		req := &http.Request{Header: http.Header{}}
+		req.Header.Set("Accept-Encoding", test.header)
+		c.Check(acceptsGzip(req), gc.Equals, test.expect)
+	}
+}
+
 func assertStreamParams(c *gc.C, obtained, expected *logStream) {
 	c.Check(obtained.includeEntity, jc.DeepEquals, expected.includeEntity)
 	c.Check(obtained.includeModule, jc.DeepEquals, expected.includeModule)
@@ -5,6 +5,7 @@ package apiserver_test
 
 import (
 	"bufio"
+	"compress/gzip"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -82,6 +83,46 @@ func (s *debugLogSuite) TestServesLog(c *gc.C) {
 	s.assertLogReader(c, reader)
 }
 
+func (s *debugLogSuite) TestServesLogGzipped(c *gc.C) {
+	s.ensureLogFile(c)
+	header := utils.BasicAuthHeader(s.userTag, s.password)
+	header.Set("Accept-Encoding", "gzip")
+	conn, err := s.dialWebsocketInternal(c, nil, header)
+	c.Assert(err, gc.IsNil)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// The initial error line is never compressed.
+	s.assertLogFollowing(c, reader)
+	s.writeLogLines(c, logLineCount)
+
+	gzReader, err := gzip.NewReader(reader)
+	c.Assert(err, gc.IsNil)
+	linesRead := s.readLogLines(c, bufio.NewReader(gzReader), logLineCount)
+	c.Assert(linesRead, jc.DeepEquals, logLines)
+}
+
+func (s *debugLogSuite) TestServesLogGzippedWithMaxLines(c *gc.C) {
+	s.ensureLogFile(c)
+	header := utils.BasicAuthHeader(s.userTag, s.password)
+	header.Set("Accept-Encoding", "gzip")
+	conn, err := s.dialWebsocketInternal(c, url.Values{"maxLines": {"10"}}, header)
+	c.Assert(err, gc.IsNil)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	s.assertLogFollowing(c, reader)
+	s.writeLogLines(c, logLineCount)
+
+	gzReader, err := gzip.NewReader(reader)
+	c.Assert(err, gc.IsNil)
+	lineReader := bufio.NewReader(gzReader)
+	linesRead := s.readLogLines(c, lineReader, 10)
+	c.Assert(linesRead, jc.DeepEquals, logLines[:10])
+	// The stream must end with a valid gzip trailer.
+	s.assertWebsocketClosed(c, lineReader)
+}
+
 func (s *debugLogSuite) TestReadFromTopLevelPath(c *gc.C) {
 	// Backwards compatibility check, that we can read the log file at
 	// https://host:port/log
@@ -0,0 +1,49 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"sync"
+)
+
+// entityLimiter limits the number of concurrent requests
+// that may be made on behalf of any single entity.
+type entityLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// newEntityLimiter returns an entityLimiter that allows at
+// most max concurrent requests for each entity.
+func newEntityLimiter(max int) *entityLimiter {
+	return &entityLimiter{
+		max:    max,
+		active: make(map[string]int),
+	}
+}
+
+// acquire reserves a request slot for the entity with the given tag,
+// and reports whether it was able to do so. A successful acquire must
+// be followed by a call to release.
+func (l *entityLimiter) acquire(tag string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[tag] >= l.max {
+		return false
+	}
+	l.active[tag]++
+	return true
+}
+
+// release releases a request slot previously reserved with acquire.
+func (l *entityLimiter) release(tag string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active[tag]--
+	if l.active[tag] <= 0 {
+		delete(l.active, tag)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// This is an internal package test.
+
+package apiserver
+
+import (
+	gc "launchpad.net/gocheck"
+
+	"github.com/juju/juju/rpc"
+	"github.com/juju/juju/testing"
+)
+
+type entityLimiterSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&entityLimiterSuite{})
+
+func (s *entityLimiterSuite) TestAcquireRelease(c *gc.C) {
+	l := newEntityLimiter(2)
+	c.Assert(l.acquire("machine-0"), gc.Equals, true)
+	c.Assert(l.acquire("machine-0"), gc.Equals, true)
+	c.Assert(l.acquire("machine-0"), gc.Equals, false)
+	// Other entities are unaffected.
+	c.Assert(l.acquire("machine-1"), gc.Equals, true)
+
+	l.release("machine-0")
+	c.Assert(l.acquire("machine-0"), gc.Equals, true)
+	l.release("machine-0")
+	l.release("machine-0")
+	l.release("machine-1")
+	c.Assert(l.active, gc.HasLen, 0)
+}
+
+func (s *entityLimiterSuite) TestIsLongLived(c *gc.C) {
+	for i, test := range []struct {
+		req    rpc.Request
+		expect bool
+	}{
+		{rpc.Request{Type: "Pinger", Action: "Ping"}, true},
+		{rpc.Request{Type: "NotifyWatcher", Id: "1", Action: "Next"}, true},
+		{rpc.Request{Type: "StringsWatcher", Id: "1", Action: "Stop"}, true},
+		{rpc.Request{Type: "Machiner", Action: "Life"}, false},
+	} {
+		c.Logf("test %d: %v", i, test.req)
+		c.Check(isLongLived(test.req), gc.Equals, test.expect)
+	}
+}
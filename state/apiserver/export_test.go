@@ -11,13 +11,14 @@ import (
 )
 
 var (
-	RootType              = reflect.TypeOf(&srvRoot{})
-	NewPingTimeout        = newPingTimeout
-	MaxClientPingInterval = &maxClientPingInterval
-	MongoPingInterval     = &mongoPingInterval
+	RootType                  = reflect.TypeOf(&srvRoot{})
+	NewPingTimeout            = newPingTimeout
+	MaxClientPingInterval     = &maxClientPingInterval
+	MongoPingInterval         = &mongoPingInterval
+	MongoPingFailureThreshold = &mongoPingFailureThreshold
 )
 
-const LoginRateLimit = loginRateLimit
+const LoginRateLimit = DefaultLoginRateLimit
 
 // DelayLogins changes how the Login code works so that logins won't proceed
 // until they get a message on the returned channel.
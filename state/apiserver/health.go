@@ -0,0 +1,43 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/juju/juju/state/api/params"
+)
+
+// healthHandler reports the health of the API server. It requires
+// no authentication, so that it can be used by load balancers.
+type healthHandler struct {
+	srv *Server
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	statusCode := http.StatusOK
+	response := params.HealthResponse{Status: "ok"}
+	if atomic.LoadInt64(&h.srv.mongoPingFailures) > 0 {
+		statusCode = http.StatusServiceUnavailable
+		response = params.HealthResponse{
+			Status: "unhealthy",
+			Error:  "cannot ping mongo",
+		}
+	}
+	h.sendJSON(w, statusCode, &response)
+}
+
+// sendJSON sends a JSON-encoded response to the client.
+func (h *healthHandler) sendJSON(w http.ResponseWriter, statusCode int, response *params.HealthResponse) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	w.Write(body)
+	return nil
+}
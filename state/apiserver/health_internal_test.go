@@ -0,0 +1,41 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// This is an internal package test.
+
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	gc "launchpad.net/gocheck"
+
+	"github.com/juju/juju/state/api/params"
+	"github.com/juju/juju/testing"
+)
+
+type healthInternalSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&healthInternalSuite{})
+
+func (s *healthInternalSuite) TestUnhealthyWhenMongoPingFails(c *gc.C) {
+	h := &healthHandler{&Server{mongoPingFailures: 1}}
+	req, err := http.NewRequest("GET", "/health", nil)
+	c.Assert(err, gc.IsNil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	c.Assert(w.Code, gc.Equals, http.StatusServiceUnavailable)
+	c.Assert(w.HeaderMap.Get("Content-Type"), gc.Equals, "application/json")
+	var result params.HealthResponse
+	err = json.Unmarshal(w.Body.Bytes(), &result)
+	c.Assert(err, gc.IsNil)
+	c.Assert(result, gc.Equals, params.HealthResponse{
+		Status: "unhealthy",
+		Error:  "cannot ping mongo",
+	})
+}
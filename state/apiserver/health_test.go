@@ -0,0 +1,36 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	gc "launchpad.net/gocheck"
+
+	"github.com/juju/juju/state/api/params"
+)
+
+type healthSuite struct {
+	authHttpSuite
+}
+
+var _ = gc.Suite(&healthSuite{})
+
+func (s *healthSuite) healthURL(c *gc.C) string {
+	uri := s.baseURL(c)
+	uri.Path = "/health"
+	return uri.String()
+}
+
+func (s *healthSuite) TestHealthy(c *gc.C) {
+	// No credentials are required.
+	resp, err := s.sendRequest(c, "", "", "GET", s.healthURL(c), "", nil)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "application/json")
+	var result params.HealthResponse
+	err = json.Unmarshal(body, &result)
+	c.Assert(err, gc.IsNil)
+	c.Assert(result, gc.Equals, params.HealthResponse{Status: "ok"})
+}
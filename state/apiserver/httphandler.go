@@ -83,6 +83,21 @@ func (h *httpHandler) validateEnvironUUID(r *http.Request) error {
 	return nil
 }
 
+// checkRequest authenticates the request and makes sure it addresses
+// this environment. If not, it sends an error response using sender,
+// and returns false.
+func (h *httpHandler) checkRequest(w http.ResponseWriter, r *http.Request, sender errorSender) bool {
+	if err := h.authenticate(r); err != nil {
+		h.authError(w, sender)
+		return false
+	}
+	if err := h.validateEnvironUUID(r); err != nil {
+		sender.sendError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
 // authError sends an unauthorized error.
 func (h *httpHandler) authError(w http.ResponseWriter, sender errorSender) {
 	w.Header().Set("WWW-Authenticate", `Basic realm="juju"`)
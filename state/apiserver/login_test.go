@@ -51,12 +51,17 @@ var badLoginTests = []struct {
 }}
 
 func (s *loginSuite) setupServer(c *gc.C) (*api.Info, func()) {
+	return s.setupServerWithLoginRateLimit(c, 0)
+}
+
+func (s *loginSuite) setupServerWithLoginRateLimit(c *gc.C, loginRateLimit int) (*api.Info, func()) {
 	srv, err := apiserver.NewServer(
 		s.State,
 		"localhost:0",
 		[]byte(coretesting.ServerCert),
 		[]byte(coretesting.ServerKey),
 		"", "",
+		loginRateLimit,
 	)
 	c.Assert(err, gc.IsNil)
 	env, err := s.State.Environment()
@@ -75,6 +80,10 @@ func (s *loginSuite) setupServer(c *gc.C) (*api.Info, func()) {
 }
 
 func (s *loginSuite) setupMachineAndServer(c *gc.C) (*api.Info, func()) {
+	return s.setupMachineAndServerWithLoginRateLimit(c, 0)
+}
+
+func (s *loginSuite) setupMachineAndServerWithLoginRateLimit(c *gc.C, loginRateLimit int) (*api.Info, func()) {
 	machine, err := s.State.AddMachine("quantal", state.JobHostUnits)
 	c.Assert(err, gc.IsNil)
 	err = machine.SetProvisioned("foo", "fake_nonce", nil)
@@ -83,7 +92,7 @@ func (s *loginSuite) setupMachineAndServer(c *gc.C) (*api.Info, func()) {
 	c.Assert(err, gc.IsNil)
 	err = machine.SetPassword(password)
 	c.Assert(err, gc.IsNil)
-	info, cleanup := s.setupServer(c)
+	info, cleanup := s.setupServerWithLoginRateLimit(c, loginRateLimit)
 	info.Tag = machine.Tag()
 	info.Password = password
 	info.Nonce = "fake_nonce"
@@ -389,6 +398,32 @@ func (s *loginSuite) TestLoginRateLimited(c *gc.C) {
 	}
 }
 
+func (s *loginSuite) TestLoginRateLimitConfigurable(c *gc.C) {
+	const loginRateLimit = 2
+	info, cleanup := s.setupMachineAndServerWithLoginRateLimit(c, loginRateLimit)
+	defer cleanup()
+	delayChan, cleanup := apiserver.DelayLogins()
+	defer cleanup()
+
+	// Max out the configured limit, which is lower than the default.
+	errResults, wg := startNLogins(c, loginRateLimit, info)
+	select {
+	case err := <-errResults:
+		c.Fatalf("we should not have gotten any logins yet: %v", err)
+	case <-time.After(coretesting.ShortWait):
+	}
+	_, err := api.Open(info, fastDialOpts)
+	c.Check(err, jc.Satisfies, params.IsCodeTryAgain)
+	for i := 0; i < loginRateLimit; i++ {
+		delayChan <- struct{}{}
+	}
+	wg.Wait()
+	close(errResults)
+	for err := range errResults {
+		c.Check(err, gc.IsNil)
+	}
+}
+
 func (s *loginSuite) TestUsersLoginWhileRateLimited(c *gc.C) {
 	info, cleanup := s.setupMachineAndServer(c)
 	defer cleanup()
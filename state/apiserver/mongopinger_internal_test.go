@@ -0,0 +1,72 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// This is an internal package test.
+
+package apiserver
+
+import (
+	"errors"
+	"time"
+
+	gc "launchpad.net/gocheck"
+	"launchpad.net/tomb"
+
+	"github.com/juju/juju/testing"
+)
+
+type mongoPingerInternalSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&mongoPingerInternalSuite{})
+
+// fakeSession returns the errors in results from successive
+// calls to Ping, and nil once they have been exhausted.
+type fakeSession struct {
+	results   []error
+	pings     int
+	refreshes int
+}
+
+func (s *fakeSession) Ping() error {
+	s.pings++
+	if len(s.results) == 0 {
+		return nil
+	}
+	err := s.results[0]
+	s.results = s.results[1:]
+	return err
+}
+
+func (s *fakeSession) Refresh() {
+	s.refreshes++
+}
+
+func (s *mongoPingerInternalSuite) TestPingMongoFailsAfterThreshold(c *gc.C) {
+	bad := errors.New("bad")
+	session := &fakeSession{results: []error{bad, bad, bad}}
+	var failures int64
+	err := pingMongo(session, 0, 3, nil, &failures)
+	c.Assert(err, gc.ErrorMatches, "error pinging mongo: bad")
+	c.Assert(session.pings, gc.Equals, 3)
+	c.Assert(session.refreshes, gc.Equals, 2)
+	c.Assert(failures, gc.Equals, int64(3))
+}
+
+func (s *mongoPingerInternalSuite) TestPingMongoSuccessResetsFailures(c *gc.C) {
+	bad := errors.New("bad")
+	session := &fakeSession{results: []error{bad, bad, nil, bad, bad, nil, bad, bad, bad}}
+	var failures int64
+	err := pingMongo(session, 0, 3, nil, &failures)
+	c.Assert(err, gc.ErrorMatches, "error pinging mongo: bad")
+	c.Assert(session.pings, gc.Equals, 9)
+}
+
+func (s *mongoPingerInternalSuite) TestPingMongoStopsWhenDying(c *gc.C) {
+	dying := make(chan struct{})
+	close(dying)
+	var failures int64
+	err := pingMongo(&fakeSession{}, time.Hour, 3, dying, &failures)
+	c.Assert(err, gc.Equals, tomb.ErrDying)
+}
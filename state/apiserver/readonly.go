@@ -0,0 +1,64 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"strings"
+
+	"github.com/juju/juju/rpc"
+)
+
+// mutatingActionPrefixes holds the prefixes of RPC action names
+// that are known to change state. Requests for matching actions are
+// rejected when the server is in read-only mode.
+var mutatingActionPrefixes = []string{
+	"Add",
+	"ClearResolved",
+	"ClosePort",
+	"Delete",
+	"Destroy",
+	"Ensure",
+	"EnterScope",
+	"EnvironmentSet",
+	"EnvironmentUnset",
+	"Import",
+	"Inject",
+	"LeaveScope",
+	"OpenPort",
+	"Remove",
+	"RetryProvisioning",
+	"Run",
+	"ServiceDeploy",
+	"ServiceDestroy",
+	"ServiceExpose",
+	"ServiceSet",
+	"ServiceUnexpose",
+	"ServiceUnset",
+	"ServiceUpdate",
+	"Set",
+	"Update",
+}
+
+// mutatingActions holds actions that change state but whose names
+// are shared with read-only actions on other facades.
+var mutatingActions = map[string]bool{
+	"Client.Resolved": true,
+}
+
+// isMutating reports whether the request is known to change state.
+// Watchers and pings never mutate state.
+func isMutating(req rpc.Request) bool {
+	if isLongLived(req) {
+		return false
+	}
+	if mutatingActions[req.Type+"."+req.Action] {
+		return true
+	}
+	for _, prefix := range mutatingActionPrefixes {
+		if strings.HasPrefix(req.Action, prefix) {
+			return true
+		}
+	}
+	return false
+}
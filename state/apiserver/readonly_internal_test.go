@@ -0,0 +1,45 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// This is an internal package test.
+
+package apiserver
+
+import (
+	gc "launchpad.net/gocheck"
+
+	"github.com/juju/juju/rpc"
+	"github.com/juju/juju/testing"
+)
+
+type readOnlySuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&readOnlySuite{})
+
+func (s *readOnlySuite) TestIsMutating(c *gc.C) {
+	for i, test := range []struct {
+		req    rpc.Request
+		expect bool
+	}{
+		{rpc.Request{Type: "Pinger", Action: "Ping"}, false},
+		{rpc.Request{Type: "AllWatcher", Id: "1", Action: "Next"}, false},
+		{rpc.Request{Type: "Client", Action: "FullStatus"}, false},
+		{rpc.Request{Type: "Client", Action: "ServiceGet"}, false},
+		{rpc.Request{Type: "Client", Action: "WatchAll"}, false},
+		{rpc.Request{Type: "Firewaller", Action: "OpenedPorts"}, false},
+		{rpc.Request{Type: "Uniter", Action: "Resolved"}, false},
+		{rpc.Request{Type: "Client", Action: "Resolved"}, true},
+		{rpc.Request{Type: "Client", Action: "ServiceDeploy"}, true},
+		{rpc.Request{Type: "Client", Action: "ServiceSetCharm"}, true},
+		{rpc.Request{Type: "Client", Action: "DestroyMachines"}, true},
+		{rpc.Request{Type: "Client", Action: "EnvironmentSet"}, true},
+		{rpc.Request{Type: "Machiner", Action: "SetStatus"}, true},
+		{rpc.Request{Type: "Uniter", Action: "EnterScope"}, true},
+		{rpc.Request{Type: "Uniter", Action: "OpenPort"}, true},
+	} {
+		c.Logf("test %d: %v", i, test.req)
+		c.Check(isMutating(test.req), gc.Equals, test.expect)
+	}
+}
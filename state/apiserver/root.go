@@ -46,11 +46,15 @@ var (
 	// depend on the interval.
 	maxClientPingInterval = 3 * time.Minute
 
-	// mongoPingInterval defines the interval at which an API server
-	// will ping the mongo session to make sure that it's still
-	// alive. When the ping returns an error, the server will be
-	// terminated.
+	// mongoPingInterval defines the default interval at which an API
+	// server will ping the mongo session to make sure that it's still
+	// alive.
 	mongoPingInterval = 10 * time.Second
+
+	// mongoPingFailureThreshold defines the default number of
+	// consecutive failed mongo pings after which the server will
+	// be terminated.
+	mongoPingFailureThreshold = 3
 )
 
 // srvRoot represents a single client's connection to the state
@@ -85,6 +89,25 @@ func (r *srvRoot) Kill() {
 	r.resources.StopAll()
 }
 
+// AdmitRequest implements rpc.RequestAdmitter. It rejects requests
+// that change state when the server is read-only, and enforces the
+// server's per-entity concurrent request limit, if there is one.
+func (r *srvRoot) AdmitRequest(req rpc.Request) (func(), error) {
+	if r.srv.readOnly && isMutating(req) {
+		return nil, common.ErrReadOnly
+	}
+	limiter := r.srv.entityLimiter
+	if limiter == nil || isLongLived(req) {
+		return nil, nil
+	}
+	tag := r.entity.Tag()
+	if !limiter.acquire(tag) {
+		logger.Debugf("%s has too many concurrent requests, try again later", tag)
+		return nil, common.ErrTryAgain
+	}
+	return func() { limiter.release(tag) }, nil
+}
+
 // requireAgent checks whether the current client is an agent and hence
 // may access the agent APIs.  We filter out non-agents when calling one
 // of the accessor functions (Machine, Unit, etc) which avoids us making
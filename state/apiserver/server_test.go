@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	stdtesting "testing"
 	"time"
 
@@ -45,7 +46,7 @@ func (s *serverSuite) TestStop(c *gc.C) {
 	srv, err := apiserver.NewServer(
 		s.State, "localhost:0",
 		[]byte(coretesting.ServerCert), []byte(coretesting.ServerKey),
-		"", "")
+		"", "", 0)
 	c.Assert(err, gc.IsNil)
 	defer srv.Stop()
 
@@ -89,6 +90,284 @@ func (s *serverSuite) TestStop(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 }
 
+func (s *serverSuite) TestNewServerWithConfig(c *gc.C) {
+	srv, err := apiserver.NewServerWithConfig(s.State, apiserver.ServerConfig{
+		Addrs: []string{"localhost:0"},
+		Cert:  []byte(coretesting.ServerCert),
+		Key:   []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+
+	info := s.APIInfo(c)
+	info.Addrs = []string{srv.Addr()}
+	st, err := api.Open(info, fastDialOpts)
+	c.Assert(err, gc.IsNil)
+	st.Close()
+
+	err = srv.Stop()
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *serverSuite) TestReadOnly(c *gc.C) {
+	srv, err := apiserver.NewServerWithConfig(s.State, apiserver.ServerConfig{
+		Addrs:    []string{"localhost:0"},
+		Cert:     []byte(coretesting.ServerCert),
+		Key:      []byte(coretesting.ServerKey),
+		ReadOnly: true,
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+
+	info := s.APIInfo(c)
+	info.Addrs = []string{srv.Addr()}
+	st, err := api.Open(info, fastDialOpts)
+	c.Assert(err, gc.IsNil)
+	defer st.Close()
+
+	// Reads succeed.
+	client := st.Client()
+	_, err = client.EnvironmentGet()
+	c.Assert(err, gc.IsNil)
+	watcher, err := client.WatchAll()
+	c.Assert(err, gc.IsNil)
+	c.Assert(watcher.Stop(), gc.IsNil)
+
+	// Writes are rejected.
+	err = client.EnvironmentSet(map[string]interface{}{"some-key": "value"})
+	c.Assert(err, gc.ErrorMatches, "api server is read only")
+	c.Assert(err, jc.Satisfies, params.IsCodeReadOnly)
+}
+
+func (s *serverSuite) TestMultipleAddrs(c *gc.C) {
+	srv, err := apiserver.NewServerWithConfig(s.State, apiserver.ServerConfig{
+		Addrs: []string{"localhost:0", "localhost:0"},
+		Cert:  []byte(coretesting.ServerCert),
+		Key:   []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+	addrs := srv.Addrs()
+	c.Assert(addrs, gc.HasLen, 2)
+	c.Assert(addrs[0], gc.Not(gc.Equals), addrs[1])
+	c.Assert(srv.Addr(), gc.Equals, addrs[0])
+
+	var states []*api.State
+	for _, addr := range addrs {
+		info := s.APIInfo(c)
+		info.Addrs = []string{addr}
+		st, err := api.Open(info, fastDialOpts)
+		c.Assert(err, gc.IsNil)
+		defer st.Close()
+		states = append(states, st)
+	}
+
+	err = srv.Stop()
+	c.Assert(err, gc.IsNil)
+	for _, st := range states {
+		err := st.Ping()
+		if err != rpc.ErrShutdown && err != io.ErrUnexpectedEOF {
+			c.Fatalf("unexpected error from request: %v", err)
+		}
+	}
+	// All the listeners have been closed.
+	for _, addr := range addrs {
+		_, err := net.Dial("tcp", addr)
+		c.Assert(err, gc.NotNil)
+	}
+}
+
+func (s *serverSuite) TestTLSConfig(c *gc.C) {
+	srv, err := apiserver.NewServerWithConfig(s.State, apiserver.ServerConfig{
+		Addrs:           []string{"localhost:0"},
+		Cert:            []byte(coretesting.ServerCert),
+		Key:             []byte(coretesting.ServerKey),
+		TLSMinVersion:   tls.VersionTLS12,
+		TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+
+	dial := func(config *tls.Config) (*tls.ConnectionState, error) {
+		config.InsecureSkipVerify = true
+		conn, err := tls.Dial("tcp", srv.Addr(), config)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		state := conn.ConnectionState()
+		return &state, nil
+	}
+	// Older TLS versions are rejected.
+	_, err = dial(&tls.Config{MaxVersion: tls.VersionTLS11})
+	c.Assert(err, gc.NotNil)
+	// Cipher suites that have not been allowed are rejected.
+	_, err = dial(&tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA},
+	})
+	c.Assert(err, gc.NotNil)
+
+	state, err := dial(&tls.Config{MaxVersion: tls.VersionTLS12})
+	c.Assert(err, gc.IsNil)
+	c.Assert(state.Version, gc.Equals, uint16(tls.VersionTLS12))
+	c.Assert(state.CipherSuite, gc.Equals, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+}
+
+func (s *serverSuite) TestMaxRequestSize(c *gc.C) {
+	srv, err := apiserver.NewServerWithConfig(s.State, apiserver.ServerConfig{
+		Addrs:          []string{"localhost:0"},
+		Cert:           []byte(coretesting.ServerCert),
+		Key:            []byte(coretesting.ServerKey),
+		MaxRequestSize: 1024,
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+	_, portString, err := net.SplitHostPort(srv.Addr())
+	c.Assert(err, gc.IsNil)
+	conn, err := dialWebsocket(c, "localhost:"+portString, "/")
+	c.Assert(err, gc.IsNil)
+	defer conn.Close()
+
+	// A small request gets a reply.
+	request := map[string]interface{}{
+		"RequestId": 1,
+		"Type":      "Admin",
+		"Request":   "Login",
+		"Params":    map[string]string{"AuthTag": "user-admin"},
+	}
+	err = websocket.JSON.Send(conn, request)
+	c.Assert(err, gc.IsNil)
+	var reply map[string]interface{}
+	err = websocket.JSON.Receive(conn, &reply)
+	c.Assert(err, gc.IsNil)
+	c.Assert(reply["RequestId"], gc.Equals, float64(1))
+
+	// An oversized request causes the connection to be closed.
+	request["RequestId"] = 2
+	request["Params"] = map[string]string{"AuthTag": strings.Repeat("x", 2048)}
+	err = websocket.JSON.Send(conn, request)
+	c.Assert(err, gc.IsNil)
+	err = websocket.JSON.Receive(conn, &reply)
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *serverSuite) TestNewServerWithConfigNoAddrs(c *gc.C) {
+	srv, err := apiserver.NewServerWithConfig(s.State, apiserver.ServerConfig{
+		Cert: []byte(coretesting.ServerCert),
+		Key:  []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.ErrorMatches, "no addresses to listen on")
+	c.Assert(srv, gc.IsNil)
+}
+
+func (s *serverSuite) TestNewServerWithConfigBadCert(c *gc.C) {
+	srv, err := apiserver.NewServerWithConfig(s.State, apiserver.ServerConfig{
+		Addrs: []string{"localhost:0"},
+		Cert:  []byte("bad cert"),
+		Key:   []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.NotNil)
+	c.Assert(srv, gc.IsNil)
+}
+
+func (s *serverSuite) TestStats(c *gc.C) {
+	srv, err := apiserver.NewServer(
+		s.State, "localhost:0",
+		[]byte(coretesting.ServerCert), []byte(coretesting.ServerKey),
+		"", "", 0)
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+	c.Assert(srv.Stats(), gc.Equals, apiserver.ServerStats{})
+
+	info := s.APIInfo(c)
+	info.Addrs = []string{srv.Addr()}
+	st, err := api.Open(info, fastDialOpts)
+	c.Assert(err, gc.IsNil)
+	err = st.Ping()
+	c.Assert(err, gc.IsNil)
+
+	stats := srv.Stats()
+	c.Assert(stats.ActiveConnections, gc.Equals, int64(1))
+	// At least the Login and Ping requests have been served.
+	c.Assert(stats.TotalRequests >= 2, gc.Equals, true)
+	c.Assert(stats.ActiveLogins, gc.Equals, int64(0))
+
+	err = st.Close()
+	c.Assert(err, gc.IsNil)
+	attempt := utils.AttemptStrategy{
+		Total: coretesting.LongWait,
+		Delay: coretesting.ShortWait,
+	}
+	for a := attempt.Start(); a.Next(); {
+		if srv.Stats().ActiveConnections == 0 {
+			return
+		}
+	}
+	c.Fatalf("timed out waiting for connection to be closed")
+}
+
+func (s *serverSuite) newServerAndConn(c *gc.C) (*apiserver.Server, *api.State) {
+	srv, err := apiserver.NewServer(
+		s.State, "localhost:0",
+		[]byte(coretesting.ServerCert), []byte(coretesting.ServerKey),
+		"", "", 0)
+	c.Assert(err, gc.IsNil)
+	info := s.APIInfo(c)
+	info.Addrs = []string{srv.Addr()}
+	st, err := api.Open(info, fastDialOpts)
+	c.Assert(err, gc.IsNil)
+	return srv, st
+}
+
+func (s *serverSuite) TestDrainWaitsForConnections(c *gc.C) {
+	srv, st := s.newServerAndConn(c)
+	defer srv.Stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Drain(coretesting.LongWait)
+	}()
+	select {
+	case err := <-done:
+		c.Fatalf("drain completed with connection still open: %v", err)
+	case <-time.After(coretesting.ShortWait):
+	}
+
+	// The existing connection is still usable, but
+	// no new connections are accepted.
+	err := st.Ping()
+	c.Assert(err, gc.IsNil)
+	info := s.APIInfo(c)
+	info.Addrs = []string{srv.Addr()}
+	_, err = api.Open(info, api.DialOpts{Timeout: coretesting.ShortWait})
+	c.Assert(err, gc.NotNil)
+
+	err = st.Close()
+	c.Assert(err, gc.IsNil)
+	select {
+	case err := <-done:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for drain to complete")
+	}
+}
+
+func (s *serverSuite) TestDrainTimeout(c *gc.C) {
+	srv, st := s.newServerAndConn(c)
+	defer st.Close()
+
+	err := srv.Drain(coretesting.ShortWait)
+	c.Assert(err, gc.IsNil)
+
+	err = st.Ping()
+	// The client has not necessarily seen the server shutdown yet,
+	// so there are two possible errors.
+	if err != rpc.ErrShutdown && err != io.ErrUnexpectedEOF {
+		c.Fatalf("unexpected error from request: %v", err)
+	}
+}
+
 func (s *serverSuite) TestOpenAsMachineErrors(c *gc.C) {
 	assertNotProvisioned := func(err error) {
 		c.Assert(err, gc.NotNil)
@@ -234,7 +513,7 @@ func (s *serverSuite) TestNonCompatiblePathsAre404(c *gc.C) {
 	srv, err := apiserver.NewServer(
 		s.State, "localhost:0",
 		[]byte(coretesting.ServerCert), []byte(coretesting.ServerKey),
-		"", "")
+		"", "", 0)
 	c.Assert(err, gc.IsNil)
 	defer srv.Stop()
 	// We have to use 'localhost' because that is what the TLS cert says.
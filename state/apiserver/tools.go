@@ -30,12 +30,7 @@ type toolsHandler struct {
 }
 
 func (h *toolsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if err := h.authenticate(r); err != nil {
-		h.authError(w, h)
-		return
-	}
-	if err := h.validateEnvironUUID(r); err != nil {
-		h.sendError(w, http.StatusNotFound, err.Error())
+	if !h.checkRequest(w, r, h) {
 		return
 	}
 
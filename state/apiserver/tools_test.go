@@ -175,7 +175,7 @@ func (s *toolsSuite) TestUploadRejectsWrongEnvUUIDPath(c *gc.C) {
 	url.Path = "/environment/dead-beef-123456/tools"
 	resp, err := s.authRequest(c, "POST", url.String(), "", nil)
 	c.Assert(err, gc.IsNil)
-	s.assertErrorResponse(c, resp, http.StatusNotFound, `unknown environment: "dead-beef-123456"`)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest, `unknown environment: "dead-beef-123456"`)
 }
 
 func (s *toolsSuite) TestUploadFakeSeries(c *gc.C) {
@@ -180,6 +180,7 @@ func addCharm(c *gc.C, st *State, series string, ch charm.Charm) *Charm {
 var MachineIdLessThan = machineIdLessThan
 
 var JobNames = jobNames
+var ReadRequestedNetworksForIds = readRequestedNetworksForIds
 
 // SCHEMACHANGE
 // This method is used to reset a deprecated machine attribute.
@@ -970,6 +970,19 @@ func (m *Machine) RequestedNetworks() ([]string, error) {
 	return readRequestedNetworks(m.st, m.globalKey())
 }
 
+// SetRequestedNetworks replaces the list of network names the
+// machine should be on.
+func (m *Machine) SetRequestedNetworks(networks []string) (err error) {
+	defer errors.Maskf(&err, "cannot set requested networks of machine %v", m)
+	if err := validateRequestedNetworks(networks); err != nil {
+		return err
+	}
+	if m.doc.Life != Alive {
+		return errNotAlive
+	}
+	return setRequestedNetworks(m.st, m.st.machines, m.doc.Id, m.globalKey(), networks)
+}
+
 // Networks returns the list of configured networks on the machine.
 // The configured and requested networks on a machine must match.
 func (m *Machine) Networks() ([]*Network, error) {
@@ -397,6 +397,69 @@ func (s *MachineSuite) TestRequestedNetworks(c *gc.C) {
 	c.Assert(networks, gc.HasLen, 0)
 }
 
+func (s *MachineSuite) TestReadRequestedNetworksForIds(c *gc.C) {
+	machine, err := s.State.AddOneMachine(state.MachineTemplate{
+		Series:            "quantal",
+		Jobs:              []state.MachineJob{state.JobHostUnits},
+		RequestedNetworks: []string{"net1", "net2"},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(machine.Id(), gc.Equals, "1")
+
+	result, err := state.ReadRequestedNetworksForIds(s.State, []string{"m#0", "m#1", "m#42"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(result, jc.DeepEquals, map[string][]string{
+		"m#0":  []string{},
+		"m#1":  []string{"net1", "net2"},
+		"m#42": []string{},
+	})
+
+	result, err = state.ReadRequestedNetworksForIds(s.State, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(result, gc.HasLen, 0)
+}
+
+func (s *MachineSuite) TestSetRequestedNetworks(c *gc.C) {
+	machine, err := s.State.AddOneMachine(state.MachineTemplate{
+		Series:            "quantal",
+		Jobs:              []state.MachineJob{state.JobHostUnits},
+		RequestedNetworks: []string{"net1", "net2"},
+	})
+	c.Assert(err, gc.IsNil)
+	err = machine.SetRequestedNetworks([]string{"net3"})
+	c.Assert(err, gc.IsNil)
+	networks, err := machine.RequestedNetworks()
+	c.Assert(err, gc.IsNil)
+	c.Assert(networks, jc.DeepEquals, []string{"net3"})
+
+	err = machine.SetRequestedNetworks([]string{"net1", "-bad-"})
+	c.Assert(err, gc.ErrorMatches, `cannot set requested networks of machine 1: invalid network names: "-bad-"`)
+
+	err = machine.SetRequestedNetworks(nil)
+	c.Assert(err, gc.IsNil)
+	networks, err = machine.RequestedNetworks()
+	c.Assert(err, gc.IsNil)
+	c.Assert(networks, gc.HasLen, 0)
+
+	// Networks cannot be set once the machine is dead.
+	err = machine.EnsureDead()
+	c.Assert(err, gc.IsNil)
+	err = machine.SetRequestedNetworks([]string{"net1"})
+	c.Assert(err, gc.ErrorMatches, `cannot set requested networks of machine 1: not found or not alive`)
+}
+
+func (s *MachineSuite) TestSetRequestedNetworksWithoutDoc(c *gc.C) {
+	// Machines in legacy databases have no requested networks document.
+	err := s.MgoSuite.Session.DB("juju").C("requestednetworks").RemoveId("m#" + s.machine.Id())
+	c.Assert(err, gc.IsNil)
+
+	err = s.machine.SetRequestedNetworks([]string{"net1"})
+	c.Assert(err, gc.IsNil)
+	networks, err := s.machine.RequestedNetworks()
+	c.Assert(err, gc.IsNil)
+	c.Assert(networks, jc.DeepEquals, []string{"net1"})
+}
+
 func addNetworkAndInterface(c *gc.C, st *state.State, machine *state.Machine,
 	networkName, providerId, cidr string, vlanTag int, isVirtual bool,
 	mac, ifaceName string,
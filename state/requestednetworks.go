@@ -4,7 +4,12 @@
 package state
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/names"
 	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
 	"labix.org/v2/mgo/txn"
 )
 
@@ -20,6 +25,21 @@ func newRequestedNetworksDoc(networks []string) *requestedNetworksDoc {
 	return &requestedNetworksDoc{Networks: networks}
 }
 
+// validateRequestedNetworks returns an error listing all the given
+// network names which are not valid. An empty list is valid.
+func validateRequestedNetworks(networks []string) error {
+	var invalid []string
+	for _, name := range networks {
+		if !names.IsNetwork(name) {
+			invalid = append(invalid, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid network names: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
 func createRequestedNetworksOp(st *State, id string, networks []string) txn.Op {
 	return txn.Op{
 		C:      st.requestedNetworks.Name,
@@ -29,7 +49,48 @@ func createRequestedNetworksOp(st *State, id string, networks []string) txn.Op {
 	}
 }
 
-// While networks are immutable, there is no setNetworksOp function.
+func setRequestedNetworksOp(st *State, id string, networks []string) txn.Op {
+	return txn.Op{
+		C:      st.requestedNetworks.Name,
+		Id:     id,
+		Assert: txn.DocExists,
+		Update: bson.D{{"$set", bson.D{{"networks", networks}}}},
+	}
+}
+
+// setRequestedNetworks replaces the requested networks with the given
+// id, as long as the entity document with the given id in coll is
+// alive. Entities in legacy databases may have no requestedNetworksDoc,
+// so one is created if necessary.
+func setRequestedNetworks(st *State, coll *mgo.Collection, entityId interface{}, id string, networks []string) error {
+	// The first attempt can only fail because of the document being
+	// created concurrently, or the entity no longer being alive, so
+	// two attempts are enough.
+	for i := 0; i < 2; i++ {
+		count, err := st.requestedNetworks.FindId(id).Count()
+		if err != nil {
+			return err
+		}
+		op := setRequestedNetworksOp(st, id, networks)
+		if count == 0 {
+			op = createRequestedNetworksOp(st, id, networks)
+		}
+		ops := []txn.Op{{
+			C:      coll.Name,
+			Id:     entityId,
+			Assert: isAliveDoc,
+		}, op}
+		if err := st.runTransaction(ops); err != txn.ErrAborted {
+			return err
+		}
+		if alive, err := isAlive(coll, entityId); err != nil {
+			return err
+		} else if !alive {
+			return errNotAlive
+		}
+	}
+	return ErrExcessiveContention
+}
 
 func removeRequestedNetworksOp(st *State, id string) txn.Op {
 	return txn.Op{
@@ -51,3 +112,28 @@ func readRequestedNetworks(st *State, id string) ([]string, error) {
 	}
 	return doc.Networks, err
 }
+
+// readRequestedNetworksForIds returns the requested networks for each
+// of the given ids, using a single query. As with readRequestedNetworks,
+// ids without a requestedNetworksDoc are not an error; they map to an
+// empty list.
+func readRequestedNetworksForIds(st *State, ids []string) (map[string][]string, error) {
+	result := make(map[string][]string, len(ids))
+	for _, id := range ids {
+		result[id] = []string{}
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var docs []requestedNetworksDoc
+	sel := bson.D{{"_id", bson.D{{"$in", ids}}}}
+	if err := st.requestedNetworks.Find(sel).All(&docs); err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if doc.Networks != nil {
+			result[doc.Id] = doc.Networks
+		}
+	}
+	return result, nil
+}
@@ -840,6 +840,18 @@ func (s *Service) Networks() ([]string, error) {
 	return readRequestedNetworks(s.st, s.globalKey())
 }
 
+// SetNetworks replaces the networks a service is associated with.
+func (s *Service) SetNetworks(networks []string) (err error) {
+	defer errors.Maskf(&err, "cannot set networks of service %q", s.doc.Name)
+	if err := validateRequestedNetworks(networks); err != nil {
+		return err
+	}
+	if s.doc.Life != Alive {
+		return errNotAlive
+	}
+	return setRequestedNetworks(s.st, s.st.services, s.doc.Name, s.globalKey(), networks)
+}
+
 // settingsIncRefOp returns an operation that increments the ref count
 // of the service settings identified by serviceName and curl. If
 // canCreate is false, a missing document will be treated as an error;
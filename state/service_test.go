@@ -1478,6 +1478,33 @@ func (s *ServiceSuite) TestNetworks(c *gc.C) {
 	c.Check(networks, gc.HasLen, 0)
 }
 
+func (s *ServiceSuite) TestSetNetworks(c *gc.C) {
+	service := s.AddTestingServiceWithNetworks(c, "withnets", s.charm, []string{"yes", "on"})
+	err := service.SetNetworks([]string{"no"})
+	c.Assert(err, gc.IsNil)
+	networks, err := service.Networks()
+	c.Assert(err, gc.IsNil)
+	c.Check(networks, gc.DeepEquals, []string{"no"})
+
+	err = service.Destroy()
+	c.Assert(err, gc.IsNil)
+	err = service.SetNetworks([]string{"yes"})
+	c.Assert(err, gc.ErrorMatches, `cannot set networks of service "withnets": not found or not alive`)
+}
+
+func (s *ServiceSuite) TestSetNetworksWithoutDoc(c *gc.C) {
+	// Services in legacy databases have no requested networks document.
+	service := s.AddTestingService(c, "legacy", s.charm)
+	err := s.MgoSuite.Session.DB("juju").C("requestednetworks").RemoveId("s#legacy")
+	c.Assert(err, gc.IsNil)
+
+	err = service.SetNetworks([]string{"net1"})
+	c.Assert(err, gc.IsNil)
+	networks, err := service.Networks()
+	c.Assert(err, gc.IsNil)
+	c.Check(networks, gc.DeepEquals, []string{"net1"})
+}
+
 func (s *ServiceSuite) TestNetworksOnService(c *gc.C) {
 	networks := []string{"yes", "on"}
 	service := s.AddTestingServiceWithNetworks(c, "withnets", s.charm, networks)
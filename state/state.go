@@ -561,6 +561,25 @@ func (st *State) Charm(curl *charm.URL) (*Charm, error) {
 	return newCharm(st, cdoc)
 }
 
+// LocalCharmURLs returns the URLs of all the local charms uploaded
+// to the environment. Charms pending upload are not included.
+func (st *State) LocalCharmURLs() ([]*charm.URL, error) {
+	var docs []charmDoc
+	what := bson.D{
+		{"_id", bson.D{{"$regex", "^local:"}}},
+		{"pendingupload", bson.D{{"$ne", true}}},
+	}
+	err := st.charms.Find(what).Select(bson.D{{"_id", 1}}).All(&docs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get local charms: %v", err)
+	}
+	curls := make([]*charm.URL, len(docs))
+	for i, doc := range docs {
+		curls[i] = doc.URL
+	}
+	return curls, nil
+}
+
 // LatestPlaceholderCharm returns the latest charm described by the
 // given URL but which is not yet deployed.
 func (st *State) LatestPlaceholderCharm(curl *charm.URL) (*Charm, error) {
@@ -939,6 +958,9 @@ func (st *State) AddService(name, ownerTag string, ch *Charm, networks []string)
 	if ch == nil {
 		return nil, fmt.Errorf("charm is nil")
 	}
+	if err := validateRequestedNetworks(networks); err != nil {
+		return nil, err
+	}
 	if exists, err := isNotDead(st.services, name); err != nil {
 		return nil, err
 	} else if exists {
@@ -259,6 +259,23 @@ func (s *StateSuite) TestPrepareLocalCharmUpload(c *gc.C) {
 	c.Assert(curl.Revision, gc.Equals, 1234)
 }
 
+func (s *StateSuite) TestLocalCharmURLs(c *gc.C) {
+	curls, err := s.State.LocalCharmURLs()
+	c.Assert(err, gc.IsNil)
+	c.Assert(curls, gc.HasLen, 0)
+
+	ch := s.AddTestingCharm(c, "dummy")
+	// Charms pending upload and store charms are not included.
+	_, err = s.State.PrepareLocalCharmUpload(charm.MustParseURL("local:quantal/pending-1"))
+	c.Assert(err, gc.IsNil)
+	err = s.State.AddStoreCharmPlaceholder(charm.MustParseURL("cs:quantal/placeholder-1"))
+	c.Assert(err, gc.IsNil)
+
+	curls, err = s.State.LocalCharmURLs()
+	c.Assert(err, gc.IsNil)
+	c.Assert(curls, jc.DeepEquals, []*charm.URL{ch.URL()})
+}
+
 func (s *StateSuite) TestPrepareStoreCharmUpload(c *gc.C) {
 	// First test the sanity checks.
 	sch, err := s.State.PrepareStoreCharmUpload(charm.MustParseURL("cs:quantal/dummy"))
@@ -1119,6 +1136,10 @@ func (s *StateSuite) TestAddService(c *gc.C) {
 	_, err = s.State.AddService("umadbro", "user-admin", nil, nil)
 	c.Assert(err, gc.ErrorMatches, `cannot add service "umadbro": charm is nil`)
 
+	// Check that invalid network names are rejected.
+	_, err = s.State.AddService("umadbro", "user-admin", charm, []string{"net1", "bad net", "^net2"})
+	c.Assert(err, gc.ErrorMatches, `cannot add service "umadbro": invalid network names: "bad net", "\^net2"`)
+
 	wordpress, err := s.State.AddService("wordpress", "user-admin", charm, nil)
 	c.Assert(err, gc.IsNil)
 	c.Assert(wordpress.Name(), gc.Equals, "wordpress")
@@ -1963,6 +1984,46 @@ func (s *StateSuite) TestWatchForEnvironConfigChanges(c *gc.C) {
 	wc.AssertNoChange()
 }
 
+func (s *StateSuite) TestWatchRequestedNetworks(c *gc.C) {
+	machine, err := s.State.AddOneMachine(state.MachineTemplate{
+		Series:            "quantal",
+		Jobs:              []state.MachineJob{state.JobHostUnits},
+		RequestedNetworks: []string{"net1"},
+	})
+	c.Assert(err, gc.IsNil)
+	w, err := s.State.WatchRequestedNetworks("m#" + machine.Id())
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, w)
+
+	wc := statetesting.NewNotifyWatcherC(c, s.State, w)
+	// Initially we get one change notification.
+	wc.AssertOneChange()
+
+	// Changing the requested networks triggers a notification.
+	err = machine.SetRequestedNetworks([]string{"net1", "net2"})
+	c.Assert(err, gc.IsNil)
+	wc.AssertOneChange()
+
+	// Changes to other machines' networks go unnoticed.
+	other, err := s.State.AddOneMachine(state.MachineTemplate{
+		Series:            "quantal",
+		Jobs:              []state.MachineJob{state.JobHostUnits},
+		RequestedNetworks: []string{"net3"},
+	})
+	c.Assert(err, gc.IsNil)
+	err = other.SetRequestedNetworks(nil)
+	c.Assert(err, gc.IsNil)
+	wc.AssertNoChange()
+
+	statetesting.AssertStop(c, w)
+	wc.AssertClosed()
+}
+
+func (s *StateSuite) TestWatchRequestedNetworksInvalidKey(c *gc.C) {
+	_, err := s.State.WatchRequestedNetworks("u#wordpress/0")
+	c.Assert(err, gc.ErrorMatches, `"u#wordpress/0" is not a machine or service key`)
+}
+
 func (s *StateSuite) TestWatchEnvironConfigCorruptConfig(c *gc.C) {
 	cfg, err := s.State.EnvironConfig()
 	c.Assert(err, gc.IsNil)
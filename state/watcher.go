@@ -1169,6 +1169,16 @@ func (st *State) WatchAPIHostPorts() NotifyWatcher {
 	return newEntityWatcher(st, st.stateServers, apiHostPortsKey)
 }
 
+// WatchRequestedNetworks returns a NotifyWatcher that notifies when
+// the requested networks of the machine or service with the given
+// global key change.
+func (st *State) WatchRequestedNetworks(id string) (NotifyWatcher, error) {
+	if !strings.HasPrefix(id, "m#") && !strings.HasPrefix(id, "s#") {
+		return nil, fmt.Errorf("%q is not a machine or service key", id)
+	}
+	return newEntityWatcher(st, st.requestedNetworks, id), nil
+}
+
 // WatchConfigSettings returns a watcher for observing changes to the
 // unit's service configuration settings. The unit must have a charm URL
 // set before this method is called, and the returned watcher will be
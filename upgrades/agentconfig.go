@@ -5,6 +5,7 @@ package upgrades
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -15,6 +16,8 @@ import (
 	"github.com/juju/juju/state/api/params"
 )
 
+// rootLogDir and rootSpoolDir hold the default rsyslog log and
+// spool directories, used unless the agent config overrides them.
 var (
 	rootLogDir   = "/var/log"
 	rootSpoolDir = "/var/spool/rsyslog"
@@ -36,11 +39,104 @@ var chownPath = func(path, username string) error {
 	return os.Chown(path, uid, gid)
 }
 
+var currentUser = user.Current
+
 var isLocalEnviron = func(envConfig *config.Config) bool {
 	return envConfig.Type() == "local"
 }
 
-func migrateLocalProviderAgentConfig(context Context) error {
+// isMigrated reports whether migrateLocalProviderAgentConfig has
+// already completed, so that re-running it after a retried upgrade
+// does not redo the filesystem work.
+func isMigrated(agentConfig agent.ConfigSetter, attrs map[string]interface{}, logDir string) bool {
+	namespace, _ := attrs["namespace"].(string)
+	container, _ := attrs["container"].(string)
+	if namespace == "" || container == "" {
+		return false
+	}
+	if agentConfig.Value(agent.Namespace) != namespace {
+		return false
+	}
+	info, err := os.Stat(logDir)
+	return err == nil && info.IsDir()
+}
+
+// symlinkLog creates a symlink to the log file at target in dir,
+// unless one is already there. The log file may legitimately not
+// have been written yet, so a link that does not resolve is only
+// logged as a warning, to help diagnose broken log relinking.
+func symlinkLog(target, dir string) error {
+	link := filepath.Join(dir, filepath.Base(target))
+	if err := os.Symlink(target, link); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("cannot symlink %q to %q: %v", target, dir, err)
+	}
+	if _, err := os.Stat(link); os.IsNotExist(err) {
+		logger.Warningf("symlink %q points to %q, which does not exist yet", link, target)
+	} else if err != nil {
+		logger.Warningf("cannot verify symlink %q: %v", link, err)
+	}
+	return nil
+}
+
+// rollback records how to undo the changes made by an upgrade step,
+// so that a step failing partway through can restore what it can.
+type rollback struct {
+	steps []rollbackStep
+}
+
+type rollbackStep struct {
+	description string
+	undo        func() error
+}
+
+// add records how to undo a change that is about to be made.
+func (r *rollback) add(description string, undo func() error) {
+	r.steps = append(r.steps, rollbackStep{description, undo})
+}
+
+// run undoes the recorded changes in reverse order, logging
+// those that could and couldn't be undone.
+func (r *rollback) run() {
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		step := r.steps[i]
+		if err := step.undo(); err != nil {
+			logger.Errorf("cannot %s: %v", step.description, err)
+			continue
+		}
+		logger.Infof("rolled back: %s", step.description)
+	}
+}
+
+// addRestoreDir records that the directory at path, if it exists,
+// should be recreated on rollback. Its contents are not restored.
+func (r *rollback) addRestoreDir(path string) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	r.add(fmt.Sprintf("recreate directory %q", path), func() error {
+		return os.MkdirAll(path, info.Mode().Perm())
+	})
+}
+
+// addRestoreFile records that the regular file at path, if it
+// exists, should be rewritten with its current contents on rollback.
+func (r *rollback) addRestoreFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Warningf("cannot read %q, it will not be restored on failure: %v", path, err)
+		return
+	}
+	r.add(fmt.Sprintf("restore file %q", path), func() error {
+		return ioutil.WriteFile(path, data, info.Mode().Perm())
+	})
+}
+
+func migrateLocalProviderAgentConfig(context Context) (err error) {
 	st := context.State()
 	if st == nil {
 		logger.Debugf("no state connection, no migration required")
@@ -62,6 +158,7 @@ func migrateLocalProviderAgentConfig(context Context) error {
 	// environment config.
 	namespace, _ := attrs["namespace"].(string)
 	container, _ := attrs["container"].(string)
+	origNamespace, origContainer := namespace, container
 
 	if namespace == "" {
 		username := os.Getenv("USER")
@@ -70,7 +167,13 @@ func migrateLocalProviderAgentConfig(context Context) error {
 			username = os.Getenv("SUDO_USER")
 		}
 		if username == "" {
-			return fmt.Errorf("cannot get current user from the environment: %v", os.Environ())
+			// There is no login shell when run under an init
+			// system, so fall back to the real user id.
+			u, err := currentUser()
+			if err != nil {
+				return fmt.Errorf("cannot get current user from the environment: %v: %v", os.Environ(), err)
+			}
+			username = u.Username
 		}
 		namespace = username + "-" + envConfig.Name()
 	}
@@ -81,7 +184,11 @@ func migrateLocalProviderAgentConfig(context Context) error {
 	dataDir := rootDir
 	localLogDir := filepath.Join(rootDir, "log")
 	// rsyslogd is restricted to write to /var/log
-	logDir := fmt.Sprintf("%s/juju-%s", rootLogDir, namespace)
+	logDir := fmt.Sprintf("%s/juju-%s", context.RootLogDir(), namespace)
+	if isMigrated(context.AgentConfig(), attrs, logDir) {
+		logger.Debugf("local provider agent config already migrated")
+		return nil
+	}
 	jobs := []params.MachineJob{params.JobManageEnviron}
 	values := map[string]string{
 		agent.Namespace: namespace,
@@ -94,42 +201,62 @@ func migrateLocalProviderAgentConfig(context Context) error {
 		"SHARED_STORAGE_DIR",
 	}
 
+	// Anything below may fail partway through, so record how to
+	// restore the original state.
+	var undo rollback
+	defer func() {
+		if err != nil {
+			logger.Errorf("local provider agent config migration failed, rolling back: %v", err)
+			undo.run()
+		}
+	}()
+
+	const migrationParts = 5
 	// Remove shared-storage dir if there.
+	context.ReportProgress(0, migrationParts, "removing shared storage")
+	undo.addRestoreDir(sharedStorageDir)
 	if err := os.RemoveAll(sharedStorageDir); err != nil {
 		return fmt.Errorf("cannot remove deprecated %q: %v", sharedStorageDir, err)
 	}
 
 	// We need to create the dirs if they don't exist.
+	context.ReportProgress(1, migrationParts, "creating data and log directories")
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return fmt.Errorf("cannot create dataDir %q: %v", dataDir, err)
 	}
 	// We always recreate the logDir to make sure it's empty.
+	undo.addRestoreDir(logDir)
 	if err := os.RemoveAll(logDir); err != nil {
 		return fmt.Errorf("cannot remove logDir %q: %v", logDir, err)
 	}
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("cannot create logDir %q: %v", logDir, err)
 	}
+	context.ReportProgress(2, migrationParts, "reconfiguring rsyslog")
 	// Reconfigure rsyslog as needed:
 	// 1. logDir must be owned by syslog:adm
 	// 2. Remove old rsyslog spool config
 	// 3. Relink logs to the new logDir
 	if err := chownPath(logDir, "syslog"); err != nil {
-		return err
+		// Not all systems have a syslog user; the ownership
+		// can be fixed manually, so carry on with the migration.
+		logger.Warningf("cannot change ownership of %q to syslog, rsyslog may not be able to write to it: %v", logDir, err)
 	}
-	spoolConfig := fmt.Sprintf("%s/machine-0-%s", rootSpoolDir, namespace)
+	spoolConfig := fmt.Sprintf("%s/machine-0-%s", context.RootSpoolDir(), namespace)
+	undo.addRestoreFile(spoolConfig)
 	if err := os.RemoveAll(spoolConfig); err != nil {
 		return fmt.Errorf("cannot remove %q: %v", spoolConfig, err)
 	}
 	allMachinesLog := filepath.Join(logDir, "all-machines.log")
-	if err := os.Symlink(allMachinesLog, localLogDir+"/"); err != nil && !os.IsExist(err) {
-		return fmt.Errorf("cannot symlink %q to %q: %v", allMachinesLog, localLogDir, err)
+	if err := symlinkLog(allMachinesLog, localLogDir); err != nil {
+		return err
 	}
 	machine0Log := filepath.Join(localLogDir, "machine-0.log")
-	if err := os.Symlink(machine0Log, logDir+"/"); err != nil && !os.IsExist(err) {
-		return fmt.Errorf("cannot symlink %q to %q: %v", machine0Log, logDir, err)
+	if err := symlinkLog(machine0Log, logDir); err != nil {
+		return err
 	}
 
+	context.ReportProgress(3, migrationParts, "updating environment config")
 	newCfg := map[string]interface{}{
 		"namespace": namespace,
 		"container": container,
@@ -137,8 +264,26 @@ func migrateLocalProviderAgentConfig(context Context) error {
 	if err := st.UpdateEnvironConfig(newCfg, nil, nil); err != nil {
 		return fmt.Errorf("cannot update environment config: %v", err)
 	}
+	undo.add("restore environment config", func() error {
+		restoreAttrs := make(map[string]interface{})
+		var removeAttrs []string
+		for key, value := range map[string]string{
+			"namespace": origNamespace,
+			"container": origContainer,
+		} {
+			if value == "" {
+				removeAttrs = append(removeAttrs, key)
+			} else {
+				restoreAttrs[key] = value
+			}
+		}
+		return st.UpdateEnvironConfig(restoreAttrs, removeAttrs, nil)
+	})
 
-	return context.AgentConfig().Migrate(agent.MigrateParams{
+	context.ReportProgress(4, migrationParts, "migrating agent config")
+	agentConfig := context.AgentConfig()
+	undo.add("restore agent config", restoreAgentConfigFunc(agentConfig, values, deprecatedValues))
+	return agentConfig.Migrate(agent.MigrateParams{
 		DataDir:      dataDir,
 		LogDir:       logDir,
 		Jobs:         jobs,
@@ -146,3 +291,30 @@ func migrateLocalProviderAgentConfig(context Context) error {
 		DeleteValues: deprecatedValues,
 	})
 }
+
+// restoreAgentConfigFunc returns a function that restores the given
+// agent config's directories, jobs and values as they are now. Values
+// for the given keys that are currently unset are deleted. Jobs are
+// only restored if there currently are some.
+func restoreAgentConfigFunc(agentConfig agent.ConfigSetter, values map[string]string, deprecatedValues []string) func() error {
+	restore := agent.MigrateParams{
+		DataDir: agentConfig.DataDir(),
+		LogDir:  agentConfig.LogDir(),
+		Jobs:    agentConfig.Jobs(),
+		Values:  make(map[string]string),
+	}
+	keys := append([]string(nil), deprecatedValues...)
+	for key := range values {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if value := agentConfig.Value(key); value != "" {
+			restore.Values[key] = value
+		} else {
+			restore.DeleteValues = append(restore.DeleteValues, key)
+		}
+	}
+	return func() error {
+		return agentConfig.Migrate(restore)
+	}
+}
@@ -4,9 +4,13 @@
 package upgrades_test
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"os/user"
 	"path/filepath"
 
+	"github.com/juju/loggo"
 	jc "github.com/juju/testing/checkers"
 	gc "launchpad.net/gocheck"
 
@@ -153,6 +157,19 @@ func (s *migrateLocalProviderAgentConfigSuite) TestMigrateStateServer(c *gc.C) {
 	s.assertConfigProcessed(c)
 }
 
+func (s *migrateLocalProviderAgentConfigSuite) TestMigrateReportsProgress(c *gc.C) {
+	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
+	err := upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.IsNil)
+	c.Assert(s.ctx.(*mockContext).progress, jc.DeepEquals, []string{
+		"0/5 removing shared storage",
+		"1/5 creating data and log directories",
+		"2/5 reconfiguring rsyslog",
+		"3/5 updating environment config",
+		"4/5 migrating agent config",
+	})
+}
+
 func (s *migrateLocalProviderAgentConfigSuite) TestMigrateNonLocalEnvNotDone(c *gc.C) {
 	s.PatchValue(upgrades.IsLocalEnviron, func(_ *config.Config) bool { return false })
 	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
@@ -186,3 +203,123 @@ func (s *migrateLocalProviderAgentConfigSuite) TestIdempotent(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	s.assertConfigProcessed(c)
 }
+
+func (s *migrateLocalProviderAgentConfigSuite) TestRerunDoesNotTouchFilesystem(c *gc.C) {
+	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
+	err := upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.IsNil)
+	err = s.config.Write()
+	c.Assert(err, gc.IsNil)
+
+	// Anything left in the log directory would be removed
+	// if the migration was done again.
+	expectedLogDir := filepath.Join(*upgrades.RootLogDir, "juju-user-dummyenv")
+	marker := filepath.Join(expectedLogDir, "marker")
+	c.Assert(ioutil.WriteFile(marker, nil, 0644), gc.IsNil)
+
+	err = upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.IsNil)
+	_, err = os.Stat(marker)
+	c.Assert(err, gc.IsNil)
+	s.assertConfigProcessed(c)
+}
+
+func (s *migrateLocalProviderAgentConfigSuite) TestRollbackOnFailure(c *gc.C) {
+	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
+	spoolConfig := filepath.Join(*upgrades.RootSpoolDir, "machine-0-user-dummyenv")
+	c.Assert(ioutil.WriteFile(spoolConfig, []byte("spool"), 0644), gc.IsNil)
+	// Removing the local log dir makes relinking the logs fail
+	// after the shared storage and spool config have been removed.
+	envConfig, err := s.State.EnvironConfig()
+	c.Assert(err, gc.IsNil)
+	rootDir, _ := envConfig.AllAttrs()["root-dir"].(string)
+	c.Assert(os.RemoveAll(filepath.Join(rootDir, "log")), gc.IsNil)
+
+	err = upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.ErrorMatches, "cannot symlink .*")
+	err = s.config.Write()
+	c.Assert(err, gc.IsNil)
+	s.assertConfigNotProcessed(c)
+	data, err := ioutil.ReadFile(spoolConfig)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "spool")
+}
+
+func (s *migrateLocalProviderAgentConfigSuite) TestChownFailureIsNotFatal(c *gc.C) {
+	s.PatchValue(upgrades.ChownPath, func(_, _ string) error {
+		return fmt.Errorf(`cannot lookup "syslog" user id: unknown user syslog`)
+	})
+	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
+	tw := &loggo.TestWriter{}
+	c.Assert(loggo.RegisterWriter("agentconfig-tester", tw, loggo.WARNING), gc.IsNil)
+	defer loggo.RemoveWriter("agentconfig-tester")
+
+	err := upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.IsNil)
+	err = s.config.Write()
+	c.Assert(err, gc.IsNil)
+	s.assertConfigProcessed(c)
+	c.Assert(tw.Log, jc.LogMatches, []jc.SimpleMessage{
+		{loggo.WARNING, `cannot change ownership of ".*" to syslog.*unknown user syslog`},
+		{loggo.WARNING, `symlink ".*/all-machines.log" points to ".*", which does not exist yet`},
+		{loggo.WARNING, `symlink ".*/machine-0.log" points to ".*", which does not exist yet`},
+	})
+}
+
+func (s *migrateLocalProviderAgentConfigSuite) TestNamespaceFromUserId(c *gc.C) {
+	s.PatchEnvironment("USER", "")
+	s.PatchEnvironment("SUDO_USER", "")
+	s.PatchValue(upgrades.CurrentUser, func() (*user.User, error) {
+		return &user.User{Username: "user"}, nil
+	})
+	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
+	err := upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.IsNil)
+	err = s.config.Write()
+	c.Assert(err, gc.IsNil)
+	s.assertConfigProcessed(c)
+}
+
+func (s *migrateLocalProviderAgentConfigSuite) TestNamespaceNoUser(c *gc.C) {
+	s.PatchEnvironment("USER", "")
+	s.PatchEnvironment("SUDO_USER", "")
+	s.PatchValue(upgrades.CurrentUser, func() (*user.User, error) {
+		return nil, fmt.Errorf("user: unknown userid 1234")
+	})
+	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
+	err := upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.ErrorMatches, "(?s)cannot get current user from the environment: .*: user: unknown userid 1234")
+	err = s.config.Write()
+	c.Assert(err, gc.IsNil)
+	s.assertConfigNotProcessed(c)
+}
+
+func (s *migrateLocalProviderAgentConfigSuite) TestSymlinkLogs(c *gc.C) {
+	s.primeConfig(c, s.State, state.JobManageEnviron, "machine-0")
+	envConfig, err := s.State.EnvironConfig()
+	c.Assert(err, gc.IsNil)
+	rootDir, _ := envConfig.AllAttrs()["root-dir"].(string)
+	localLogDir := filepath.Join(rootDir, "log")
+	// The machine log exists, but all-machines.log
+	// is yet to be written by rsyslog.
+	machine0Log := filepath.Join(localLogDir, "machine-0.log")
+	c.Assert(ioutil.WriteFile(machine0Log, nil, 0644), gc.IsNil)
+	tw := &loggo.TestWriter{}
+	c.Assert(loggo.RegisterWriter("agentconfig-tester", tw, loggo.WARNING), gc.IsNil)
+	defer loggo.RemoveWriter("agentconfig-tester")
+
+	err = upgrades.MigrateLocalProviderAgentConfig(s.ctx)
+	c.Assert(err, gc.IsNil)
+
+	logDir := filepath.Join(*upgrades.RootLogDir, "juju-user-dummyenv")
+	allMachinesLog := filepath.Join(logDir, "all-machines.log")
+	target, err := os.Readlink(filepath.Join(localLogDir, "all-machines.log"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(target, gc.Equals, allMachinesLog)
+	target, err = os.Readlink(filepath.Join(logDir, "machine-0.log"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(target, gc.Equals, machine0Log)
+	c.Assert(tw.Log, jc.LogMatches, []jc.SimpleMessage{
+		{loggo.WARNING, `symlink ".*/all-machines.log" points to ".*", which does not exist yet`},
+	})
+}
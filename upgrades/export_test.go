@@ -11,6 +11,7 @@ var (
 
 	ChownPath      = &chownPath
 	IsLocalEnviron = &isLocalEnviron
+	CurrentUser    = &currentUser
 
 	// 118 upgrade functions
 	StepsFor118                            = stepsFor118
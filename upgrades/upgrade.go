@@ -86,8 +86,24 @@ type Context interface {
 	// AgentConfig returns the agent config for the machine that is being
 	// upgraded.
 	AgentConfig() agent.ConfigSetter
+
+	// ReportProgress reports that done out of total parts of the
+	// current upgrade step have been performed, with a description
+	// of the part now being performed.
+	ReportProgress(done, total int, description string)
+
+	// RootLogDir returns the directory that rsyslog writes logs to.
+	RootLogDir() string
+
+	// RootSpoolDir returns the directory that rsyslog keeps its
+	// spool files in.
+	RootSpoolDir() string
 }
 
+// ProgressFunc is called with each progress report made by an
+// upgrade step.
+type ProgressFunc func(done, total int, description string)
+
 // upgradeContext is a default Context implementation.
 type upgradeContext struct {
 	// Work in progress........
@@ -96,6 +112,7 @@ type upgradeContext struct {
 	api         *api.State
 	st          *state.State
 	agentConfig agent.ConfigSetter
+	progress    ProgressFunc
 }
 
 // APIState is defined on the Context interface.
@@ -113,12 +130,41 @@ func (c *upgradeContext) AgentConfig() agent.ConfigSetter {
 	return c.agentConfig
 }
 
-// NewContext returns a new upgrade context.
-func NewContext(agentConfig agent.ConfigSetter, api *api.State, st *state.State) Context {
+// ReportProgress is defined on the Context interface.
+func (c *upgradeContext) ReportProgress(done, total int, description string) {
+	logger.Infof("upgrade step progress: %d of %d: %s", done, total, description)
+	if c.progress != nil {
+		c.progress(done, total, description)
+	}
+}
+
+// RootLogDir is defined on the Context interface. It may be
+// overridden with the agent config's RsyslogLogDir value.
+func (c *upgradeContext) RootLogDir() string {
+	if dir := c.agentConfig.Value(agent.RsyslogLogDir); dir != "" {
+		return dir
+	}
+	return rootLogDir
+}
+
+// RootSpoolDir is defined on the Context interface. It may be
+// overridden with the agent config's RsyslogSpoolDir value.
+func (c *upgradeContext) RootSpoolDir() string {
+	if dir := c.agentConfig.Value(agent.RsyslogSpoolDir); dir != "" {
+		return dir
+	}
+	return rootSpoolDir
+}
+
+// NewContext returns a new upgrade context. If progress is
+// not nil, it is called with each progress report made by an
+// upgrade step.
+func NewContext(agentConfig agent.ConfigSetter, api *api.State, st *state.State, progress ProgressFunc) Context {
 	return &upgradeContext{
 		api:         api,
 		st:          st,
 		agentConfig: agentConfig,
+		progress:    progress,
 	}
 }
 
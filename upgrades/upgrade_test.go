@@ -5,6 +5,7 @@ package upgrades_test
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 	stdtesting "testing"
@@ -78,6 +79,7 @@ func (u *mockUpgradeStep) Run(context upgrades.Context) error {
 
 type mockContext struct {
 	messages        []string
+	progress        []string
 	agentConfig     *mockAgentConfig
 	realAgentConfig agent.ConfigSetter
 	apiState        *api.State
@@ -99,6 +101,18 @@ func (c *mockContext) AgentConfig() agent.ConfigSetter {
 	return c.agentConfig
 }
 
+func (c *mockContext) RootLogDir() string {
+	return *upgrades.RootLogDir
+}
+
+func (c *mockContext) RootSpoolDir() string {
+	return *upgrades.RootSpoolDir
+}
+
+func (c *mockContext) ReportProgress(done, total int, description string) {
+	c.progress = append(c.progress, fmt.Sprintf("%d/%d %s", done, total, description))
+}
+
 type mockAgentConfig struct {
 	agent.ConfigSetter
 	dataDir      string
@@ -291,6 +305,32 @@ func (s *upgradeSuite) TestPerformUpgrade(c *gc.C) {
 	}
 }
 
+func (s *upgradeSuite) TestContextReportProgress(c *gc.C) {
+	var reports []string
+	ctx := upgrades.NewContext(nil, nil, nil, func(done, total int, description string) {
+		reports = append(reports, fmt.Sprintf("%d/%d %s", done, total, description))
+	})
+	ctx.ReportProgress(0, 2, "first")
+	ctx.ReportProgress(1, 2, "second")
+	c.Assert(reports, jc.DeepEquals, []string{"0/2 first", "1/2 second"})
+
+	// Reporting without a progress func is fine.
+	ctx = upgrades.NewContext(nil, nil, nil, nil)
+	ctx.ReportProgress(0, 1, "only")
+}
+
+func (s *upgradeSuite) TestContextRsyslogDirs(c *gc.C) {
+	config := &mockAgentConfig{values: map[string]string{}}
+	ctx := upgrades.NewContext(config, nil, nil, nil)
+	c.Assert(ctx.RootLogDir(), gc.Equals, "/var/log")
+	c.Assert(ctx.RootSpoolDir(), gc.Equals, "/var/spool/rsyslog")
+
+	config.values[agent.RsyslogLogDir] = "/srv/log"
+	config.values[agent.RsyslogSpoolDir] = "/srv/spool/rsyslog"
+	c.Assert(ctx.RootLogDir(), gc.Equals, "/srv/log")
+	c.Assert(ctx.RootSpoolDir(), gc.Equals, "/srv/spool/rsyslog")
+}
+
 func (s *upgradeSuite) TestUpgradeOperationsOrdered(c *gc.C) {
 	var previous version.Number
 	for i, utv := range (*upgrades.UpgradeOperations)() {